@@ -0,0 +1,74 @@
+package autotag
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ArtifactSpec is a file in a language ecosystem's format that WriteVersionArtifacts stamps with the
+// new version. Only the version field is replaced, the rest of the file is preserved.
+type ArtifactSpec struct {
+	// Format selects the version field to update:
+	//   * "go": a `Version` constant, eg: `const Version = "1.2.3"` in version.go
+	//   * "json": the first "version" field, eg: in package.json
+	//   * "toml": the first `version` key, eg: in the [project] table of pyproject.toml
+	Format string
+
+	// Path is the file to update, relative to the repository root unless absolute. It must exist.
+	Path string
+}
+
+// artifactRexes match the version field of each artifact format, the first group is kept
+var artifactRexes = map[string]*regexp.Regexp{
+	"go":   regexp.MustCompile(`(?m)^(\s*(?:const\s+)?Version\s*=\s*)"[^"]*"`),
+	"json": regexp.MustCompile(`("version"\s*:\s*)"[^"]*"`),
+	"toml": regexp.MustCompile(`(?m)^(\s*version\s*=\s*)"[^"]*"`),
+}
+
+// WriteVersionArtifacts writes the new version into the files configured in VersionArtifacts. Nothing
+// is written in a DryRun.
+func (r *GitRepo) WriteVersionArtifacts() error {
+	if r.dryRun && len(r.versionArtifacts) > 0 {
+		log.Println("Dry run, not writing version artifacts")
+		return nil
+	}
+	for _, a := range r.versionArtifacts {
+		if err := r.writeArtifact(a); err != nil {
+			return fmt.Errorf("error writing version to '%s': %s", a.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeArtifact replaces the first version field of the artifact, keeping the file mode
+func (r *GitRepo) writeArtifact(a ArtifactSpec) error {
+	path := a.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.repoPath, path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rex := artifactRexes[a.Format]
+	loc := rex.FindSubmatchIndex(data)
+	if loc == nil {
+		return fmt.Errorf("no %s version field found", a.Format)
+	}
+
+	// the version only contains characters that need no escaping in Go, JSON or TOML strings
+	var out []byte
+	out = append(out, data[:loc[3]]...)
+	out = append(out, fmt.Sprintf("%q", r.LatestVersion())...)
+	out = append(out, data[loc[1]:]...)
+	return os.WriteFile(path, out, info.Mode())
+}
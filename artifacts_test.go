@@ -0,0 +1,171 @@
+package autotag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+	"github.com/gogs/git-module"
+)
+
+func TestWriteVersionArtifacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name:   "go constant",
+			format: "go",
+			file:   "version.go",
+			content: `package version
+
+// Version is stamped by autotag
+const Version = "0.0.0-dev"
+
+var Commit = ""
+`,
+			expected: `package version
+
+// Version is stamped by autotag
+const Version = "1.1.0"
+
+var Commit = ""
+`,
+		},
+		{
+			name:   "go constant in a block",
+			format: "go",
+			file:   "version.go",
+			content: `package version
+
+const (
+	Name    = "tool"
+	Version = "1.0.0"
+)
+`,
+			expected: `package version
+
+const (
+	Name    = "tool"
+	Version = "1.1.0"
+)
+`,
+		},
+		{
+			name:   "package.json",
+			format: "json",
+			file:   "package.json",
+			content: `{
+  "name": "tool",
+  "version": "1.0.0",
+  "dependencies": {
+    "left-pad": "^1.3.0"
+  }
+}
+`,
+			expected: `{
+  "name": "tool",
+  "version": "1.1.0",
+  "dependencies": {
+    "left-pad": "^1.3.0"
+  }
+}
+`,
+		},
+		{
+			name:   "pyproject.toml",
+			format: "toml",
+			file:   "pyproject.toml",
+			content: `[project]
+name = "tool"
+version = "1.0.0"
+`,
+			expected: `[project]
+name = "tool"
+version = "1.1.0"
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "[minor] new feature")
+
+			path := filepath.Join(tr, tc.file)
+			checkFatal(t, os.WriteFile(path, []byte(tc.content), 0o644))
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:         tr,
+				Branch:           "main",
+				VersionArtifacts: []ArtifactSpec{{Format: tc.format, Path: tc.file}},
+			})
+			checkFatal(t, err)
+			checkFatal(t, r.WriteVersionArtifacts())
+
+			data, err := os.ReadFile(path)
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestWriteVersionArtifactsDryRun(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[minor] new feature")
+
+	path := filepath.Join(tr, "version.go")
+	content := "package tool\n\nconst Version = \"1.0.0\"\n"
+	checkFatal(t, os.WriteFile(path, []byte(content), 0o644))
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:         tr,
+		Branch:           "main",
+		DryRun:           true,
+		VersionArtifacts: []ArtifactSpec{{Format: "go", Path: "version.go"}},
+	})
+	checkFatal(t, err)
+	checkFatal(t, r.WriteVersionArtifacts())
+
+	data, err := os.ReadFile(path)
+	checkFatal(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestWriteVersionArtifactsErrors(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{initialTag: "v1.0.0"})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	dir := t.TempDir()
+	noField := filepath.Join(dir, "package.json")
+	checkFatal(t, os.WriteFile(noField, []byte(`{"name": "tool"}`), 0o644))
+
+	r.versionArtifacts = []ArtifactSpec{{Format: "json", Path: noField}}
+	assert.EqualError(t, r.WriteVersionArtifacts(), "error writing version to '"+noField+"': no json version field found")
+
+	missing := filepath.Join(dir, "version.go")
+	r.versionArtifacts = []ArtifactSpec{{Format: "go", Path: missing}}
+	assert.Error(t, r.WriteVersionArtifacts())
+
+	_, err = NewRepo(GitRepoConfig{
+		RepoPath:         r.repo.Path(),
+		Branch:           "main",
+		VersionArtifacts: []ArtifactSpec{{Format: "yaml", Path: "chart.yaml"}},
+	})
+	assert.EqualError(t, err, "version artifact format 'yaml' is not valid; must be (go|json|toml)")
+}
@@ -4,14 +4,22 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gogs/git-module"
 	"github.com/hashicorp/go-version"
@@ -20,9 +28,46 @@ import (
 const (
 	// datetimeTsLayout is the YYYYMMDDHHMMSS time format
 	datetimeTsLayout = "20060102150405"
+
+	// tagPrefixDateLayout is the YYYYMMDD time format the {date} placeholder in TagPrefix expands to
+	tagPrefixDateLayout = "20060102"
+
+	// schemeEnvVar sets the scheme when none is configured
+	schemeEnvVar = "AUTOTAG_SCHEME"
+
+	// git config keys read by applyGitConfigDefaults
+	gitConfigScheme         = "autotag.scheme"
+	gitConfigPrefix         = "autotag.prefix"
+	gitConfigPreReleaseName = "autotag.prereleasename"
+
+	// bumpFileName is the file at the root of the repository that overrides the commit based bump
+	bumpFileName = ".autotag-bump"
+
+	// ignoreTagsFileName is the file at the root of the repository listing globs of tags to ignore
+	ignoreTagsFileName = ".autotag-ignore-tags"
+
+	// maxRefComponentLength is the longest name, in bytes, of a ref path component. Loose refs are
+	// stored as files, which most filesystems limit to 255 bytes.
+	maxRefComponentLength = 255
+
+	// futureCommitTolerance is the clock skew allowed by RejectFutureCommits
+	futureCommitTolerance = 5 * time.Minute
+
+	// recentTagsChecked is how many of the highest tags are checked for a pre-release name used as
+	// build metadata
+	recentTagsChecked = 10
+
+	// gitlinkMode is the git tree entry mode of a submodule pointer
+	gitlinkMode = "160000"
+
+	// defaultTagRefNamespace is where git stores tags
+	defaultTagRefNamespace = "refs/tags"
 )
 
 var (
+	// defaultBranchFallback are the branches tried when no Branch or BranchFallback is configured
+	defaultBranchFallback = []string{"main", "master"}
+
 	// autotag commit message scheme:
 	majorRex = regexp.MustCompile(`(?i)\[major\]|\#major`)
 	minorRex = regexp.MustCompile(`(?i)\[minor\]|\#minor`)
@@ -46,8 +91,32 @@ var (
 		"test":     patchBumper,
 	}
 
-	// versionRex matches semVer style versions, eg: `v1.0.0`
-	versionRex = regexp.MustCompile(`^v?([\d]+\.?.*)`)
+	// trailerKeyRex matches a valid git trailer key, eg: `Semver-Bump`
+	trailerKeyRex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+	// bumpNoteRex matches the bump line of a BumpNotesRef note, eg: `bump=minor`
+	bumpNoteRex = regexp.MustCompile(`(?m)^bump=[ \t]*(\S+)[ \t]*$`)
+
+	// resetTrailerRex matches the trailer of a commit restarting the version history, eg: `Autotag-Reset: 1.0.0`
+	resetTrailerRex = regexp.MustCompile(`(?m)^Autotag-Reset:[ \t]*(\S+)[ \t]*$`)
+
+	// gitmojiShortcodeRex matches a leading gitmoji shortcode, eg: `:sparkles:`
+	gitmojiShortcodeRex = regexp.MustCompile(`^:[a-z0-9_+-]+:`)
+
+	// nextBumpRex matches the line of a base tag annotation declaring the next bump, eg: `Next-Bump: minor`
+	nextBumpRex = regexp.MustCompile(`(?m)^Next-Bump:[ \t]*(\S+)[ \t]*$`)
+
+	// noReleaseRex matches the marker blocking a release from the branch head, eg: `[no-release]`
+	noReleaseRex = regexp.MustCompile(`(?i)\[no-release\]|\#no-release`)
+
+	// versionRex matches semVer style versions with an optional `v` or `V` leader, eg: `v1.0.0`
+	versionRex = regexp.MustCompile(`^[vV]?([\d]+\.?.*)`)
+
+	// legacyVersionCoreRex matches the numeric part of versions using historical separators, eg: `v1_2_3`
+	legacyVersionCoreRex = regexp.MustCompile(`^[vV]?\d+(?:[._-]\d+){1,2}`)
+
+	// aliasRex matches floating alias tags, eg: `v1` or `v1.2`
+	aliasRex = regexp.MustCompile(`^[vV]?\d+(\.\d+)?$`)
 
 	// semVerPreReleaseName validates SemVer according to
 	// https://semver.org/#spec-item-9
@@ -56,19 +125,58 @@ var (
 	// semVerBuildMetaRex validates SemVer build metadata strings according to
 	// https://semver.org/#spec-item-10
 	semVerBuildMetaRex = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+	// envMetadataSanitizeRex matches the characters replaced by a hyphen in MetadataFromEnv values
+	envMetadataSanitizeRex = regexp.MustCompile(`[^0-9A-Za-z.-]`)
+
+	// branchMetadataSanitizeRex matches the characters replaced by a hyphen in the branch name with
+	// MetadataIncludeBranch, a single identifier so dots are replaced too
+	branchMetadataSanitizeRex = regexp.MustCompile(`[^0-9A-Za-z-]+`)
 )
 
+// parallelParseThreshold is the number of commits from which they are matched concurrently, and can
+// be changed in tests
+var parallelParseThreshold = 1000
+
 var timeNow = time.Now
 
+// ErrEmptyBranch is returned when the branch to be tagged exists but has no commits yet.
+var ErrEmptyBranch = errors.New("branch has no commits")
+
+// ErrNothingToTag is returned when there are no commits since the latest version tag under
+// StrictMatch, ie: the commit was already released. See SkipWhenNothingToTag.
+var ErrNothingToTag = errors.New("no version to bump for the same commit")
+
+// ErrReleaseBlocked is returned when the branch head commit message has a `[no-release]` or
+// `#no-release` marker.
+var ErrReleaseBlocked = errors.New("release blocked by the branch head commit")
+
+// ErrTooSoon is returned when the latest release is more recent than the MinReleaseInterval.
+var ErrTooSoon = errors.New("too soon since the latest release")
+
+// ErrAlreadyStabilized is returned under the "error" PreReleaseStabilizedPolicy when the base version
+// was already released as stable after pre-releases of it in the same channel.
+var ErrAlreadyStabilized = errors.New("pre-release base was already stabilized")
+
+// ErrNotConfirmed is returned by AutoTag when the Confirm hook declines to create the tag.
+var ErrNotConfirmed = errors.New("tag creation was not confirmed")
+
 // GitRepoConfig is the configuration needed to create a new *GitRepo.
 type GitRepoConfig struct {
 	// Repo is the path to the root of the git repository.
 	RepoPath string
 
 	// Branch is the name of the git branch to be tracked for tags. This value
-	// must be provided.
+	// must be provided. A remote-tracking branch, eg: origin/main, is used when
+	// there is no local branch with the name.
 	Branch string
 
+	// BranchFallback are the branches tried in order when Branch is empty or not found, eg:
+	// []string{"main", "master", "develop"}. A local branch is preferred over a remote-tracking one.
+	// Without it an empty Branch falls back to main, then master, and a Branch that is not found is
+	// an error.
+	BranchFallback []string
+
 	// PreReleaseName is the optional string to be appended to a tag being
 	// generated (e.g., v.1.2.3-pre) to indicate the pre-release type.
 	//
@@ -108,6 +216,11 @@ type GitRepoConfig struct {
 	// 		v1.2.3-pre.1
 	PreReleaseNumber bool
 
+	// MaxPreReleaseNumber promotes to the stable version, dropping the pre-release, when the
+	// PreReleaseNumber would exceed it, eg: with a maximum of 3 the release after v1.2.3-pre.3 is
+	// v1.2.3. 0 disables the limit.
+	MaxPreReleaseNumber int
+
 	// BuildMetadata is an optional string appended by a plus sign and a series of dot separated
 	// identifiers immediately following the patch or pre-release version. Identifiers MUST comprise
 	// only ASCII alphanumerics and hyphen [0-9A-Za-z-]. Identifiers MUST NOT be empty. Build metadata
@@ -117,8 +230,19 @@ type GitRepoConfig struct {
 	// https://semver.org/#spec-item-10
 	BuildMetadata string
 
+	// MetadataFromEnv lists environment variables whose values are appended as build metadata
+	// identifiers, after BuildMetadata, eg: []string{"GITHUB_RUN_NUMBER"} gives 1.2.3+42. Characters
+	// not allowed in SemVer build metadata are replaced by a hyphen and unset variables are skipped.
+	MetadataFromEnv []string
+
+	// MetadataIncludeBranch appends the branch name as the last build metadata identifier, eg: 1.2.3+main,
+	// to tell apart versions built on different branches. Characters not allowed in an identifier are
+	// replaced by a hyphen, eg: `release/2.x` gives 1.2.3+release-2-x. With BuildNumber the number comes
+	// first, eg: 1.2.3+42.main, and is read from the first identifier of the latest tag.
+	MetadataIncludeBranch bool
+
 	// Scheme is the versioning scheme to use when determining the version of the next
-	// tag. If not specified the default "autotag" is used.
+	// tag. If not specified the AUTOTAG_SCHEME environment variable is used, then the default "autotag".
 	//
 	//   * "autotag" (default if not specified):
 	//
@@ -130,19 +254,368 @@ type GitRepoConfig struct {
 	//
 	//   * "conventional" implements the Conventional Commits v1.0.0 scheme.
 	//     * https://www.conventionalcommits.org/en/v1.0.0/#summary w
+	//
+	// A comma separated list, eg: "autotag,conventional", applies every scheme to each commit and
+	// uses the highest bump found, eg: for repos migrating from one scheme to another.
 	Scheme string
 
-	// Prefix prepends literal 'v' to the tag, eg: v1.0.0. Enabled by default
+	// TagRefNamespace is the reference namespace version tags are read from and written to. If not
+	// specified the default "refs/tags" is used. Mirrors with unusual layouts can store tags under a
+	// custom namespace, eg: "refs/release-tags".
+	TagRefNamespace string
+
+	// VersionStrategy implements parsing, ordering, bumping and formatting of versions. If not
+	// specified SemVerStrategy is used.
+	VersionStrategy VersionStrategy
+
+	// FourSegment uses FourSegmentStrategy for `Major.Minor.Patch.Revision` tags, eg: v1.2.3.4. By
+	// default tags with more than three segments are skipped, as SemVer bumps are undefined for them.
+	// Not compatible with VersionStrategy.
+	FourSegment bool
+
+	// Prefix prepends literal 'v' to the tag, eg: v1.0.0. Enabled by default, ignored when TagPrefix is set
 	Prefix bool
 
+	// TagPrefix is an optional string prepended to the tag instead of the literal 'v' enabled by Prefix.
+	// The prefix may contain a {date} placeholder which is expanded to the UTC date (YYYYMMDD) NewRepo
+	// runs on, the same for every tag of the run, eg: `nightly-{date}-` produces
+	// `nightly-20190101-1.2.3`. Existing tags starting with the prefix (matching any date) have it
+	// stripped before their version is parsed.
+	TagPrefix string
+
+	// PathScope versions one module of a monorepo, eg: "frontend". Only the commits touching the path
+	// are checked for a bump, and only the tags starting with the TagPrefix are parsed. The TagPrefix
+	// defaults to the path, eg: `frontend/v1.2.3` with Prefix or `frontend/1.2.3` without. Use
+	// SkipWhenNothingToTag to not tag a module without changes.
+	PathScope string
+
+	// TagFormatter assembles the tag name of new versions, for fully custom tag shapes. If not
+	// specified DefaultTagFormatter is used. Tags are still parsed with TagPrefix and the
+	// VersionStrategy, so a custom shape should be recognized by them.
+	TagFormatter TagFormatter
+
 	// StrictMatch enforces strict mode on the scheme parsers, returning an error if no match is found.
 	// This is useful for CI/CD pipelines where you want to ensure that the commit message adheres to the scheme.
 	// Disabled by default.
 	StrictMatch bool
 
+	// RejectAmbiguousDirectives returns an error for a commit mixing different bump directives of the
+	// autotag scheme, eg: `[major] [minor] thing`, instead of silently applying the largest bump.
+	// Disabled by default.
+	RejectAmbiguousDirectives bool
+
+	// EmptyMessagePolicy decides how a commit with an empty or whitespace only message is treated,
+	// independent of StrictMatch: "patch" bumps patch, "skip" ignores the commit and "error" returns an
+	// error. By default such a commit has no bump, which is an error with StrictMatch. A commit with a
+	// BumpNotesRef note gets the bump of the note instead.
+	EmptyMessagePolicy string
+
+	// BumpTrailerKey is the key of a commit message trailer declaring the bump of the commit, eg:
+	// `Semver-Bump: minor` for "Semver-Bump". The trailer takes precedence over the Scheme, which is
+	// only used for commits without it. Its value must be major, minor or patch.
+	BumpTrailerKey string
+
+	// BumpNotesRef is a git notes ref, eg: "bumps" for refs/notes/bumps, whose notes override the bump of
+	// the commit they are attached to, so a decision can be changed after the fact without rewriting
+	// the commit message. A note with a `bump=<bump>` line, where the bump is major, minor or patch,
+	// takes precedence over the trailer and the Scheme.
+	BumpNotesRef string
+
+	// StripLeadingEmoji removes a leading emoji, eg: `✨` or the `:sparkles:` shortcode, and the
+	// whitespace after it from commit messages before the scheme parses them, eg: for gitmoji style
+	// `✨ feat: add thing` commits.
+	// Disabled by default.
+	StripLeadingEmoji bool
+
+	// EmojiBumps maps the leading emoji of a commit message to a bump, "major", "minor" or "patch",
+	// used when the scheme finds no bump in the commit, eg: {"💥": "major", ":bug:": "patch"}.
+	EmojiBumps map[string]string
+
+	// LockMajor prevents the major version from ever advancing, a major bump is applied as a minor
+	// bump instead, eg: to keep a project at 0.x.
+	// Disabled by default.
+	LockMajor bool
+
+	// SkipWhenNothingToTag treats a branch with no commits since the latest version tag as nothing to
+	// do rather than an error, even with StrictMatch: NewRepo succeeds, LatestVersion reports the
+	// current version and AutoTag creates no tag.
+	// Disabled by default.
+	SkipWhenNothingToTag bool
+
 	// BuildNumber enforces append build number in metadata (after '+' character), returns error if metadata is not a unsigned integer or empty.
 	// Disabled by default.
 	BuildNumber bool
+
+	// BuildNumberResetOnError restarts the build number at 1 when the latest tag's metadata is not an
+	// unsigned integer, instead of returning an error. Only used with BuildNumber.
+	BuildNumberResetOnError bool
+
+	// MaxSubjectLength is the maximum number of characters allowed in a commit subject (the first line
+	// of the commit message). It is only enforced when StrictMatch is enabled, returning an error
+	// identifying the offending commit. Zero disables the check.
+	MaxSubjectLength int
+
+	// SkipMergeCommits ignores merge commits (commits with more than one parent) when looking for
+	// version bumps, so subjects like "Merge pull request #123" do not influence the next version.
+	// The commits brought in by the merge are still parsed.
+	// Disabled by default.
+	SkipMergeCommits bool
+
+	// SubmoduleBumps treats commits that only update submodule pointers as patch bumps when their
+	// message has no bump directive, so releases driven by submodule updates also pass StrictMatch.
+	// Disabled by default.
+	SubmoduleBumps bool
+
+	// IgnoreSubmoduleCommits ignores commits that only update submodule pointers when looking for
+	// version bumps. Not compatible with SubmoduleBumps.
+	// Disabled by default.
+	IgnoreSubmoduleCommits bool
+
+	// RejectFutureCommits returns an error if a commit in the range is dated after the current time,
+	// allowing a few minutes of clock skew, before bad dates affect timestamp based pre-releases or
+	// date filters. The date is selected with DateSource.
+	// Disabled by default.
+	RejectFutureCommits bool
+
+	// ChangeHeuristics infers the bump from the files changed since the base tag when no commit
+	// message in the range has a bump directive: changes matching BreakingPaths are a major bump,
+	// changes matching PublicAPIPaths a minor bump and any other change a patch bump. It is a best
+	// effort heuristic for repos without disciplined commit messages, not a replacement for them.
+	// Disabled by default.
+	ChangeHeuristics bool
+
+	// PublicAPIPaths are globs of the files or directories of the public API, eg: "api" or
+	// "pkg/*/api.go", used by ChangeHeuristics. A directory matches every file below it.
+	PublicAPIPaths []string
+
+	// BreakingPaths are globs of the files or directories whose changes are breaking, used by
+	// ChangeHeuristics. A directory matches every file below it.
+	BreakingPaths []string
+
+	// CreateIntermediateTags creates a tag at every commit where the calculated version crosses a
+	// bump boundary, in addition to the final tag at the head of the branch. Eg: a patch commit
+	// followed by a minor commit on top of v1.0.0 tags v1.0.1 at the first commit and v1.1.0 at the
+	// head. The intermediate tags are only created by AutoTag. They are plain releases, so it cannot be
+	// combined with a pre-release, build metadata or a release train.
+	CreateIntermediateTags bool
+
+	// GoModuleCompat marks versions with a major version of 2 or more as `+incompatible` in
+	// GoModuleVersion, as the Go toolchain does for modules without a `/vN` module path suffix.
+	GoModuleCompat bool
+
+	// FloatingAliases also points `v1` and `v1.2` style alias tags at the commit of every new stable
+	// release, eg: tagging v1.2.3 moves v1 and v1.2 to the same commit. Alias tags are lightweight;
+	// an existing annotated tag with an alias name is never overwritten. Alias tags are ignored when
+	// looking for the latest version.
+	FloatingAliases bool
+
+	// CheckRefCollision refuses to create a tag with the same name as an existing branch, which
+	// makes the name ambiguous for git. It applies to every tag AutoTag creates or moves, eg: the
+	// floating aliases.
+	CheckRefCollision bool
+
+	// DockerTagSeparator replaces the `+` before build metadata in DockerTag, which Docker does not
+	// allow in image tags. One of `_` (default), `.` or `-`.
+	DockerTagSeparator string
+
+	// TagPattern is an optional glob, using the syntax of path.Match, that every tag must match
+	// before it is created or moved, including the floating aliases, eg: `v[0-9]*`. It is a safety net for configuration mistakes that would
+	// produce unexpected tag names, such as a missing prefix.
+	TagPattern string
+
+	// LegacySeparators accepts existing tags using `_` or `-` between the numeric segments of the
+	// version, eg: `v1_2_3` or `v1-2-3`, by normalizing them to `.` before parsing. New tags always
+	// use `.`. Disabled by default.
+	LegacySeparators bool
+
+	// DryRun makes AutoTag run the checks on the new tag and log it instead of creating it, eg: in pull
+	// request validation. Nothing else is written: no aliases, manifest, state file or release, and the
+	// Confirm and Publisher hooks are not called. AutoTag still returns the tag that would have been
+	// created.
+	DryRun bool
+
+	// DryRunValidate additionally checks in a DryRun that the tag could be written, eg: that it does
+	// not exist yet and the ref can be locked, by preparing and aborting the ref update. Requires DryRun.
+	DryRunValidate bool
+
+	// Confirm is an optional hook called by AutoTag with the tag name before any tag is created. If
+	// it returns false nothing is tagged and AutoTag returns ErrNotConfirmed. An error from the hook
+	// is returned as-is.
+	Confirm func(tag string) (bool, error)
+
+	// RemoveBumpFile deletes the .autotag-bump file after AutoTag succeeds, so the decision it records
+	// is only used once. When a .autotag-bump file containing `major`, `minor`, `patch` or `none`
+	// exists at the root of the repository it overrides the bump found in the commit messages; with
+	// `none` nothing is tagged.
+	RemoveBumpFile bool
+
+	// Push pushes the new version tag to the Remote after AutoTag creates it, before the Publisher is
+	// called, along with the intermediate tags, the release tag and the floating aliases, which are
+	// forced. If the push fails the local tags are kept and the error says the push failed.
+	// Disabled by default.
+	Push bool
+
+	// Remote is the remote Push and PushTag push to, "origin" if empty.
+	Remote string
+
+	// DualTag makes AutoTag create an annotated release tag next to the version tag, on the same
+	// commit, eg: a lightweight `v1.2.3` for tooling and an annotated `release/v1.2.3` for humans. The
+	// release tag is named by the ReleaseTagPrefix followed by the version tag, its message is the
+	// TagMessage, or the version. The version tag is only annotated with Annotated or Sign. Both tags
+	// are signed with Sign and go through the same checks, eg: TagPattern.
+	// Disabled by default.
+	DualTag bool
+
+	// ReleaseTagPrefix is prepended to the version tag to name the release tag of DualTag, "release/"
+	// if empty.
+	ReleaseTagPrefix string
+
+	// Publisher is an optional hook called by AutoTag after the tag is created, eg: to create a
+	// GitHub or GitLab release. No implementation is provided by this package.
+	Publisher Publisher
+
+	// DateSource selects the commit date used wherever commit time matters, such as TagsBetween and
+	// ordering tags that only differ in build metadata: "author" (default) or "committer". The
+	// dates differ in rebased or cherry-picked histories.
+	DateSource string
+
+	// ChangelogByPR groups the commits of GroupedChangelog under the merge commit that brought them
+	// in, eg: for release notes listing pull requests in merge based repos.
+	// Disabled by default.
+	ChangelogByPR bool
+
+	// ForgeType selects the URL shape of CompareURL: "github" (default) or "gitlab".
+	ForgeType string
+
+	// ReleaseTrain sets the minor version to the release train of the current date, for fixed
+	// cadence releases, while patch increments within the train. The first release of a new train
+	// resets the patch, eg: v1.201852.3 -> v1.201901.0, and a major bump still advances the major.
+	// The train number is derived from the UTC date:
+	//   * "isoweek": ISO year and week, YYYYWW, eg: 201901 for the week of 2019-01-01
+	//   * "month": year and month, YYYYMM, eg: 201901 for January 2019
+	// Disabled by default.
+	ReleaseTrain string
+
+	// MinReleaseInterval is the minimum time between releases, to rate limit automated releases from
+	// rapid merges. When the most recently created version tag is newer, ErrTooSoon is returned.
+	// Zero disables the check.
+	MinReleaseInterval time.Duration
+
+	// Metrics is an optional callback invoked with the Stats collected while calculating the next
+	// version, eg: to export counters when running autotag across many repositories.
+	Metrics func(Stats)
+
+	// ChannelOrder is the promotion order of pre-release channels, lowest first, eg: []string{"dev",
+	// "canary", "rc"}. Pre-release tags of the same version are compared by the position of their
+	// channel, the first pre-release identifier, instead of lexically, so 1.0.0-canary.1 sorts above
+	// 1.0.0-dev.3. Channels not listed sort below the listed ones, and as usual among themselves.
+	ChannelOrder []string
+
+	// StableChannel is a build metadata identifier marking stable tags, eg: "stable" for v1.2.3+stable.
+	// When set only tags without a pre-release that carry the marker are used as the base, and new
+	// stable versions get the marker. Not compatible with BuildNumber.
+	StableChannel string
+
+	// StrictPreReleaseOrdering verifies, when PreReleaseName and PreReleaseNumber are set, that the
+	// generated pre-release versions sort in increasing order as the pre-release number grows, eg:
+	// `rc.9` before `rc.10`, using the configured VersionStrategy. Configurations that would produce
+	// out of order versions are rejected by NewRepo.
+	StrictPreReleaseOrdering bool
+
+	// PreReleaseStabilizedPolicy decides the pre-release version when the base version was already
+	// released as stable after pre-releases of it in the PreReleaseName channel, eg: v1.0.2-dev.1 and
+	// v1.0.2. With "bump-base", the default, the next pre-release is of the bumped base version, eg:
+	// v1.0.3-dev.1. With "error" ErrAlreadyStabilized is returned, to catch a pre-release that was
+	// meant for the version already released.
+	PreReleaseStabilizedPolicy string
+
+	// AllowPreReleaseBase uses the latest pre-release tag as the base for the next version when no
+	// stable version tag exists, instead of returning an error. A patch bump finalizes the pre-release
+	// base (eg: 1.0.0-rc.2 -> 1.0.0) while major and minor bumps apply as usual.
+	// Disabled by default.
+	AllowPreReleaseBase bool
+
+	// ManifestFile is an optional path to a JSON file which AutoTag appends a record of each release to
+	// (version, tag, commit SHA, date and bump). The file is replaced atomically on every write.
+	ManifestFile string
+
+	// StateFile is an optional path to a file recording the branch head processed by each run. When
+	// it names a commit between the base tag and the branch head, the commits since that commit are
+	// reported by PreviousRunBump and Stats, eg: for a dashboard previewing the changes since it last
+	// ran. The new version is always calculated from the base tag. The file is written by a successful
+	// AutoTag, except in a DryRun.
+	StateFile string
+
+	// VersionArtifacts are files, such as version.go or package.json, which WriteVersionArtifacts
+	// updates with the new version, except in a DryRun.
+	VersionArtifacts []ArtifactSpec
+
+	// RequireSignedSuperseded makes the unsigned tags sorting above the base tag, which
+	// RequireSignedBaseTag reports by Warnings, an error instead. Requires RequireSignedBaseTag.
+	// Disabled by default.
+	RequireSignedSuperseded bool
+
+	// BaseTagLabel selects the base tag by a line in an annotated tag's message, eg: `Autotag-Base: true`.
+	// The highest version tag carrying the label is used as the base even if higher tags exist. If no
+	// tag carries the label the latest stable tag is used as usual.
+	BaseTagLabel string
+
+	// BaseVersion is the version the next version is calculated from instead of the latest stable tag,
+	// eg: `1.2.3`, when bootstrapping a repository or when versions are managed outside of its tags.
+	// Requires BaseCommit, the commit BaseVersion was released at, where the scan for commits stops.
+	BaseVersion string
+
+	// BaseCommit is the revision BaseVersion was released at. Requires BaseVersion.
+	BaseCommit string
+
+	// BumpFromTagAnnotation applies the bump declared by a `Next-Bump: major|minor|patch` line in the
+	// annotation of the base tag when no commit in the range has a bump directive, eg: to declare the
+	// scope of the next release when tagging the current one.
+	// Disabled by default.
+	BumpFromTagAnnotation bool
+
+	// RequireSignedBaseTag verifies the signature of the latest stable tag (the base the new version is
+	// calculated from) with `git verify-tag`, returning an error if it is unsigned or cannot be verified.
+	// The tags that sort above the base tag and are ignored, such as pre-releases, are verified too and
+	// any that are unsigned are reported by Warnings.
+	// Disabled by default.
+	RequireSignedBaseTag bool
+
+	// TagMessageIncludePrevious creates the new version tag as an annotated tag whose message includes
+	// the tag of the version it was calculated from, eg: `Previous-Version: v1.2.2`, so the release
+	// chain can be walked from the tags alone. Only valid with the default refs/tags namespace.
+	// Disabled by default.
+	TagMessageIncludePrevious bool
+
+	// TagMessageIncludeIssues creates the new version tag as an annotated tag whose message lists the
+	// issues and pull requests closed by the commits in the release, eg: `Closes: #123, #456`, from
+	// `Closes #123` or `Fixes #456` lines. Only valid with the default refs/tags namespace.
+	// Disabled by default.
+	TagMessageIncludeIssues bool
+
+	// Annotated creates the new version tag as an annotated tag, so `git describe` and release tooling
+	// show its message and tagger. Only valid with the default refs/tags namespace.
+	// Disabled by default.
+	Annotated bool
+
+	// TagMessage is the message of the annotated tag, the version if empty. It is a text/template with
+	// the fields {{.Version}} and {{.PreviousVersion}}, eg: `Release {{.Version}}`. Requires Annotated,
+	// Sign or DualTag; with DualTag alone only the release tag has the message.
+	TagMessage string
+
+	// Sign creates the new version tag as a GPG signed annotated tag, as `git tag -s` does, with the
+	// TagMessage. Only valid with the default refs/tags namespace.
+	// Disabled by default.
+	Sign bool
+
+	// SigningKey is the key the tag is signed with, as for `git tag -u`. If empty git uses the
+	// user.signingkey config or the committer identity. Requires Sign.
+	SigningKey string
+
+	// AllowedBranches restricts the branches AutoTag may tag, eg: []string{"main", "release"}, so a
+	// misconfigured run on a feature branch returns an error instead of tagging. The version can still
+	// be calculated on any branch. Empty allows any branch.
+	AllowedBranches []string
 }
 
 // GitRepo represents a repository we want to run actions against
@@ -151,35 +624,163 @@ type GitRepo struct {
 
 	currentVersion *version.Version
 	currentTag     *git.Commit
+	currentTagName string
 	newVersion     *version.Version
 	branch         string
 	branchID       string // commit id of the branch latest commit (where we will apply the tag)
 
 	curPreReleaseVer *version.Version
 	latestTagVersion *version.Version
-	latestTagCommit  *git.Commit
 
 	preReleaseName            string
 	preReleaseTimestampLayout string
 	preReleaseNumber          bool
+	maxPreReleaseNumber       int
+	stabilizedPolicy          string
 	buildMetadata             string
+	metadataFromEnv           []string
+	metadataIncludeBranch     bool
+
+	strategy VersionStrategy
+
+	schemes          []string
+	strictMatch      bool
+	maxSubjectLength int
+	skipMergeCommits bool
+	submoduleBumps   bool
+	ignoreSubmodules bool
+	rejectFuture     bool
+	changeHeuristics bool
+	publicAPIPaths   []string
+	breakingPaths    []string
+
+	prefix          bool
+	tagPrefix       string
+	tagDate         string // the {date} of the TagPrefix, resolved once for every tag of the run
+	tagPrefixRex    *regexp.Regexp
+	pathScope       string
+	tagRefNamespace string
+	tagPattern      string
+	tagFormatter    TagFormatter
+
+	// cfg is the configuration in effect, after defaults
+	cfg GitRepoConfig
+
+	checkRefCollision bool
+
+	buildNumber             bool
+	buildNumberResetOnError bool
+
+	requireSignedBaseTag    bool
+	requireSignedSuperseded bool
+	baseTagLabel            string
+	baseVersion             string
+	baseCommit              string
+	bumpFromTagAnnotation   bool
+	allowPreReleaseBase     bool
+
+	manifestFile string
+
+	// stateFile records the last processed commit, rangeStart is the commit read from it and
+	// previousRunBump the highest bump of the commits since then
+	stateFile       string
+	rangeStart      string
+	previousRunBump bumper
+
+	repoPath         string
+	versionArtifacts []ArtifactSpec
+
+	legacySeparators       bool
+	goModuleCompat         bool
+	dockerTagSeparator     string
+	floatingAliases        bool
+	createIntermediateTags bool
+	intermediateTags       []intermediateTag
+	createdRef             string
+
+	// tags are the parsed version tags, newest version first
+	tags       []TagInfo
+	dateSource string
+	forgeType  string
+
+	changelogByPR bool
+
+	dryRun           bool
+	dryRunValidate   bool
+	push             bool
+	remote           string
+	dualTag          bool
+	releaseTagPrefix string
+	confirm          func(tag string) (bool, error)
+	publisher        Publisher
+
+	bumpFilePath   string
+	ignoreTagsPath string
+	removeBumpFile bool
+	noBump         bool
+
+	// bump is the highest bump applied, after LockMajor
+	bump bumper
+
+	skipWhenNothingToTag bool
+	lockMajor            bool
+	rejectAmbiguous      bool
+	emptyMessagePolicy   string
+	bumpTrailerRex       *regexp.Regexp
+	bumpNotesRef         string
+	bumpNotes            map[string]string // by commit ID, read before the commits are matched
+	stripLeadingEmoji    bool
+	emojiBumps           map[string]string
+	channelOrder         []string
+	stableChannel        string
+	releaseTrain         string
+	minReleaseInterval   time.Duration
+	lastReleased         int64
+	includePrevious      bool
+	includeIssues        bool
+	annotated            bool
+	tagMessage           string
+	sign                 bool
+	signingKey           string
+	allowedBranches      []string
+
+	stats    Stats
+	matches  []CommitMatch
+	warnings []string
+}
 
-	scheme      string
-	strictMatch bool
-
-	prefix bool
+// intermediateTag is a version crossed at a commit between the base tag and the branch head
+type intermediateTag struct {
+	version  *version.Version
+	commitID string
+}
 
-	buildNumber bool
+// CalculateVersion returns the next version of the repository and the bump it was calculated with,
+// exactly as NewRepo would, eg: to list the next versions of many repositories. Nothing is written.
+// Without a version bump the current version and BumpNone are returned.
+func CalculateVersion(cfg GitRepoConfig) (*version.Version, BumpType, error) {
+	r, err := NewRepo(cfg)
+	if err != nil {
+		return nil, BumpNone, err
+	}
+	return r.newVersion, r.BumpType(), nil
 }
 
-// NewRepo is a constructor for a repo object, parsing the tags that exist
+// NewRepo is a constructor for a repo object, parsing the tags that exist. It calculates the new version
+// without writing anything, the tag and the StateFile are written by AutoTag.
 func NewRepo(cfg GitRepoConfig) (*GitRepo, error) {
-	if err := validateConfig(cfg); err != nil {
-		return nil, err
+	if cfg.Scheme == "" {
+		if scheme := os.Getenv(schemeEnvVar); scheme != "" {
+			if !validScheme(scheme) {
+				return nil, fmt.Errorf("%s '%s' is not valid; must be (autotag|conventional)", schemeEnvVar, scheme)
+			}
+			cfg.Scheme = scheme
+		}
 	}
 
-	if cfg.PreReleaseTimestampLayout == "datetime" {
-		cfg.PreReleaseTimestampLayout = datetimeTsLayout
+	// an empty path is the current directory, as for filepath.Abs
+	if _, err := os.Stat(filepath.Clean(cfg.RepoPath)); err != nil {
+		return nil, fmt.Errorf("repository path '%s' does not exist: %w", cfg.RepoPath, err)
 	}
 
 	gitDirPath, err := generateGitDirPath(cfg.RepoPath)
@@ -187,10 +788,32 @@ func NewRepo(cfg GitRepoConfig) (*GitRepo, error) {
 		return nil, err
 	}
 
-	if _, err := os.Stat(gitDirPath); os.IsNotExist(err) {
+	if _, err := os.Stat(gitDirPath); err != nil {
+		return nil, fmt.Errorf("repository path '%s' is not a git repository: %w", cfg.RepoPath, err)
+	}
+
+	if err := applyGitConfigDefaults(gitDirPath, &cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateConfig(cfg); err != nil {
 		return nil, err
 	}
 
+	if cfg.PreReleaseTimestampLayout == "datetime" {
+		cfg.PreReleaseTimestampLayout = datetimeTsLayout
+	}
+
+	if cfg.PathScope != "" {
+		cfg.PathScope = path.Clean(filepath.ToSlash(cfg.PathScope))
+		if cfg.TagPrefix == "" {
+			cfg.TagPrefix = cfg.PathScope + "/"
+			if cfg.Prefix {
+				cfg.TagPrefix += "v"
+			}
+		}
+	}
+
 	log.Println("Opening repo at", gitDirPath)
 	repo, err := git.Open(gitDirPath)
 	if err != nil {
@@ -198,26 +821,20 @@ func NewRepo(cfg GitRepoConfig) (*GitRepo, error) {
 	}
 
 	if cfg.Branch == "" {
-		branches, err := repo.Branches()
-		if err != nil {
-			return nil, err
+		fallback := cfg.BranchFallback
+		if len(fallback) == 0 {
+			fallback = defaultBranchFallback
 		}
-
-		// Locate main or master branch.
-		// If main is found, stop searching and use it.
-		// If master is found first, store it, but keep searching for main.
-		for _, b := range branches {
-			if b == "main" {
-				cfg.Branch = "main"
-				break
-			}
-			if b == "master" {
-				cfg.Branch = "master"
-			}
+		if cfg.Branch = findBranch(repo, fallback); cfg.Branch == "" {
+			return nil, fmt.Errorf("no %s branch found", strings.Join(fallback, " or "))
 		}
-		if cfg.Branch == "" {
-			return nil, fmt.Errorf("no main or master branch found")
+	} else if len(cfg.BranchFallback) > 0 && findBranch(repo, []string{cfg.Branch}) == "" {
+		b := findBranch(repo, cfg.BranchFallback)
+		if b == "" {
+			return nil, fmt.Errorf("branch '%s' not found, and no %s fallback branch found", cfg.Branch, strings.Join(cfg.BranchFallback, " or "))
 		}
+		log.Printf("Branch '%s' not found, using fallback branch '%s'", cfg.Branch, b)
+		cfg.Branch = b
 	}
 
 	r := &GitRepo{
@@ -226,11 +843,123 @@ func NewRepo(cfg GitRepoConfig) (*GitRepo, error) {
 		preReleaseName:            cfg.PreReleaseName,
 		preReleaseTimestampLayout: cfg.PreReleaseTimestampLayout,
 		preReleaseNumber:          cfg.PreReleaseNumber,
+		maxPreReleaseNumber:       cfg.MaxPreReleaseNumber,
+		stabilizedPolicy:          cfg.PreReleaseStabilizedPolicy,
 		buildMetadata:             cfg.BuildMetadata,
-		scheme:                    cfg.Scheme,
+		metadataFromEnv:           cfg.MetadataFromEnv,
+		metadataIncludeBranch:     cfg.MetadataIncludeBranch,
+		strategy:                  cfg.VersionStrategy,
+		schemes:                   splitSchemes(cfg.Scheme),
 		prefix:                    cfg.Prefix,
+		tagPrefix:                 cfg.TagPrefix,
+		tagDate:                   timeNow().UTC().Format(tagPrefixDateLayout),
+		pathScope:                 cfg.PathScope,
+		tagFormatter:              cfg.TagFormatter,
+		tagRefNamespace:           cfg.TagRefNamespace,
+		tagPattern:                cfg.TagPattern,
+		checkRefCollision:         cfg.CheckRefCollision,
 		strictMatch:               cfg.StrictMatch,
+		skipWhenNothingToTag:      cfg.SkipWhenNothingToTag,
+		lockMajor:                 cfg.LockMajor,
+		maxSubjectLength:          cfg.MaxSubjectLength,
+		skipMergeCommits:          cfg.SkipMergeCommits,
+		submoduleBumps:            cfg.SubmoduleBumps,
+		ignoreSubmodules:          cfg.IgnoreSubmoduleCommits,
+		rejectFuture:              cfg.RejectFutureCommits,
+		changeHeuristics:          cfg.ChangeHeuristics,
+		publicAPIPaths:            cfg.PublicAPIPaths,
+		breakingPaths:             cfg.BreakingPaths,
 		buildNumber:               cfg.BuildNumber,
+		buildNumberResetOnError:   cfg.BuildNumberResetOnError,
+		requireSignedBaseTag:      cfg.RequireSignedBaseTag,
+		requireSignedSuperseded:   cfg.RequireSignedSuperseded,
+		baseTagLabel:              cfg.BaseTagLabel,
+		baseVersion:               cfg.BaseVersion,
+		baseCommit:                cfg.BaseCommit,
+		bumpFromTagAnnotation:     cfg.BumpFromTagAnnotation,
+		allowPreReleaseBase:       cfg.AllowPreReleaseBase,
+		createIntermediateTags:    cfg.CreateIntermediateTags,
+		legacySeparators:          cfg.LegacySeparators,
+		goModuleCompat:            cfg.GoModuleCompat,
+		dockerTagSeparator:        cfg.DockerTagSeparator,
+		floatingAliases:           cfg.FloatingAliases,
+		dryRun:                    cfg.DryRun,
+		dryRunValidate:            cfg.DryRunValidate,
+		push:                      cfg.Push,
+		remote:                    cfg.Remote,
+		dualTag:                   cfg.DualTag,
+		releaseTagPrefix:          cfg.ReleaseTagPrefix,
+		confirm:                   cfg.Confirm,
+		publisher:                 cfg.Publisher,
+		dateSource:                cfg.DateSource,
+		forgeType:                 cfg.ForgeType,
+		changelogByPR:             cfg.ChangelogByPR,
+		bumpFilePath:              filepath.Join(cfg.RepoPath, bumpFileName),
+		ignoreTagsPath:            filepath.Join(cfg.RepoPath, ignoreTagsFileName),
+		removeBumpFile:            cfg.RemoveBumpFile,
+		manifestFile:              cfg.ManifestFile,
+		stateFile:                 cfg.StateFile,
+		repoPath:                  cfg.RepoPath,
+		versionArtifacts:          cfg.VersionArtifacts,
+		releaseTrain:              cfg.ReleaseTrain,
+		minReleaseInterval:        cfg.MinReleaseInterval,
+		includePrevious:           cfg.TagMessageIncludePrevious,
+		includeIssues:             cfg.TagMessageIncludeIssues,
+		annotated:                 cfg.Annotated,
+		tagMessage:                cfg.TagMessage,
+		sign:                      cfg.Sign,
+		signingKey:                cfg.SigningKey,
+		allowedBranches:           cfg.AllowedBranches,
+		rejectAmbiguous:           cfg.RejectAmbiguousDirectives,
+		emptyMessagePolicy:        cfg.EmptyMessagePolicy,
+		bumpNotesRef:              cfg.BumpNotesRef,
+		stripLeadingEmoji:         cfg.StripLeadingEmoji,
+		emojiBumps:                cfg.EmojiBumps,
+		channelOrder:              cfg.ChannelOrder,
+		stableChannel:             cfg.StableChannel,
+	}
+
+	if r.strategy == nil {
+		r.strategy = SemVerStrategy{}
+		if cfg.FourSegment {
+			r.strategy = FourSegmentStrategy{}
+		}
+	}
+
+	if r.tagRefNamespace == "" {
+		r.tagRefNamespace = defaultTagRefNamespace
+	}
+
+	if r.dockerTagSeparator == "" {
+		r.dockerTagSeparator = "_"
+	}
+
+	if r.remote == "" {
+		r.remote = "origin"
+	}
+
+	if r.releaseTagPrefix == "" {
+		r.releaseTagPrefix = "release/"
+	}
+
+	r.cfg = cfg
+	r.cfg.Scheme = strings.Join(r.schemes, ",")
+	r.cfg.VersionStrategy = r.strategy
+	r.cfg.TagRefNamespace = r.tagRefNamespace
+	r.cfg.DockerTagSeparator = r.dockerTagSeparator
+	r.cfg.Remote = r.remote
+	r.cfg.ReleaseTagPrefix = r.releaseTagPrefix
+
+	if r.tagPrefix != "" {
+		r.tagPrefixRex = tagPrefixRegexp(r.tagPrefix)
+	}
+
+	if cfg.BumpTrailerKey != "" {
+		r.bumpTrailerRex = regexp.MustCompile(`(?mi)^` + cfg.BumpTrailerKey + `:[ \t]*(\S+)[ \t]*$`)
+	}
+
+	if err = r.retrieveBranchInfo(); err != nil {
+		return nil, err
 	}
 
 	err = r.parseTags()
@@ -238,14 +967,34 @@ func NewRepo(cfg GitRepoConfig) (*GitRepo, error) {
 		return nil, err
 	}
 
+	if r.stateFile != "" {
+		if err = r.loadState(); err != nil {
+			return nil, err
+		}
+	}
+
 	if err = r.calcVersion(); err != nil {
 		return nil, err
 	}
 
+	// a failing TagFormatter is reported before anything relies on the tag name
+	if _, err = r.tagName(r.newVersion); err != nil {
+		return nil, err
+	}
+
+	r.stats.Bump = r.bumpName()
+	if cfg.Metrics != nil {
+		cfg.Metrics(r.stats)
+	}
+
 	return r, nil
 }
 
 func validateConfig(cfg GitRepoConfig) error {
+	if cfg.Scheme != "" && !validScheme(cfg.Scheme) {
+		return fmt.Errorf("scheme '%s' is not valid; must be (autotag|conventional)", cfg.Scheme)
+	}
+
 	if cfg.BuildMetadata != "" && !validateSemVerBuildMetadata(cfg.BuildMetadata) {
 		return fmt.Errorf("'%s' is not valid SemVer build metadata", cfg.BuildMetadata)
 	}
@@ -254,345 +1003,2469 @@ func validateConfig(cfg GitRepoConfig) error {
 		return fmt.Errorf("'%s' is not valid, cannot input metadata if enable build number", cfg.BuildMetadata)
 	}
 
-	if cfg.PreReleaseName != "" && !validateSemVerPreReleaseName(cfg.PreReleaseName) {
-		return fmt.Errorf("'%s' is not valid SemVer pre-release name", cfg.PreReleaseName)
+	for _, p := range cfg.PublicAPIPaths {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("public API path '%s' is not valid: %s", p, err)
+		}
 	}
 
-	switch cfg.PreReleaseTimestampLayout {
-	case "", "datetime", "epoch":
-		// nothing -- valid values
-	default:
-		return fmt.Errorf("pre-release-timestamp '%s' is not valid; must be (datetime|epoch)", cfg.PreReleaseTimestampLayout)
+	for _, p := range cfg.BreakingPaths {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("breaking path '%s' is not valid: %s", p, err)
+		}
 	}
 
-	return nil
-}
+	for emoji, name := range cfg.EmojiBumps {
+		if _, ok := namedBumpers[name]; !ok {
+			return fmt.Errorf("emoji bump '%s' for '%s' is not valid; must be (major|minor|patch)", name, emoji)
+		}
+	}
 
-func generateGitDirPath(repoPath string) (string, error) {
-	absolutePath, err := filepath.Abs(repoPath)
-	if err != nil {
-		return "", err
+	if cfg.SubmoduleBumps && cfg.IgnoreSubmoduleCommits {
+		return fmt.Errorf("submodule bumps cannot be enabled if submodule commits are ignored")
 	}
 
-	return filepath.Join(absolutePath, ".git"), nil
-}
+	if cfg.StableChannel != "" && !semVerBuildMetaRex.MatchString(cfg.StableChannel) {
+		return fmt.Errorf("stable channel '%s' is not valid; must be a single SemVer build metadata identifier", cfg.StableChannel)
+	}
 
-// Parse tags on repo, sort them, and store the most recent revision in the repo object
-func (r *GitRepo) parseTags() error {
-	log.Println("Parsing repository tags")
+	if cfg.BuildNumber && cfg.StableChannel != "" {
+		return fmt.Errorf("stable channel is not valid, cannot input metadata if enable build number")
+	}
 
-	versions := make(map[*version.Version]*git.Commit)
+	if cfg.BuildNumber && len(cfg.MetadataFromEnv) > 0 {
+		return fmt.Errorf("metadata from env is not valid, cannot input metadata if enable build number")
+	}
 
-	tags, err := r.repo.Tags()
-	if err != nil {
-		return fmt.Errorf("failed to fetch tags: %s", err.Error())
+	if cfg.PreReleaseName != "" && !validateSemVerPreReleaseName(cfg.PreReleaseName) {
+		return fmt.Errorf("'%s' is not valid SemVer pre-release name", cfg.PreReleaseName)
 	}
 
-	for tag, commit := range tags {
-		v, err := maybeVersionFromTag(commit)
-		if err != nil {
-			log.Println("skipping non version tag: ", tag)
-			continue
+	for _, c := range cfg.ChannelOrder {
+		if !semVerPreReleaseName.MatchString(c) {
+			return fmt.Errorf("channel '%s' is not valid; must be a single SemVer pre-release identifier", c)
 		}
+	}
 
-		if v == nil {
-			log.Println("skipping non version tag: ", tag)
-			continue
+	if cfg.TagRefNamespace != "" {
+		if !strings.HasPrefix(cfg.TagRefNamespace, "refs/") || strings.HasSuffix(cfg.TagRefNamespace, "/") || checkRefFormat(cfg.TagRefNamespace) != nil {
+			return fmt.Errorf("tag ref namespace '%s' is not valid; must be a reference path under refs/, eg: refs/tags", cfg.TagRefNamespace)
 		}
+	}
 
-		c, err := r.repo.CommitByRevision(commit)
-		if err != nil {
-			return fmt.Errorf("error reading commit '%s':  %s", commit, err)
+	if cfg.TagPattern != "" {
+		if _, err := path.Match(cfg.TagPattern, ""); err != nil {
+			return fmt.Errorf("tag pattern '%s' is not valid: %s", cfg.TagPattern, err)
 		}
-		versions[v] = c
 	}
 
-	keys := make([]*version.Version, 0, len(versions))
-	for key := range versions {
-		keys = append(keys, key)
+	switch cfg.DockerTagSeparator {
+	case "", "_", ".", "-":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("docker tag separator '%s' is not valid; must be (_|.|-)", cfg.DockerTagSeparator)
 	}
-	sort.Sort(sort.Reverse(version.Collection(keys)))
 
-	// loop over the tags and find the last reachable non pre-release tag,
-	// because we want to calculate the tag from v1.2.3 not v1.2.4-pre1.`
-	for i, version := range keys {
-		// stamps latest tag
-		if i == 0 {
-			r.latestTagVersion = version
-			r.latestTagCommit = versions[version]
-		}
+	switch cfg.DateSource {
+	case "", "author", "committer":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("date source '%s' is not valid; must be (author|committer)", cfg.DateSource)
+	}
 
-		// stamps latest tag for pre-release
-		if r.preReleaseName != "" && version.Prerelease() != "" && r.curPreReleaseVer == nil {
-			if strings.HasPrefix(version.Prerelease(), fmt.Sprintf("%s.", r.preReleaseName)) {
-				r.curPreReleaseVer = version
-			}
-		}
+	switch cfg.ForgeType {
+	case "", "github", "gitlab":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("forge type '%s' is not valid; must be (github|gitlab)", cfg.ForgeType)
+	}
 
-		if len(version.Prerelease()) == 0 {
-			r.currentVersion = version
-			r.currentTag = versions[version]
-			return nil
-		}
-		log.Printf("skipping pre-release tag version: %s", version.String())
+	if cfg.MinReleaseInterval < 0 {
+		return fmt.Errorf("min release interval '%s' is not valid; must not be negative", cfg.MinReleaseInterval)
 	}
 
-	return fmt.Errorf("no stable (non pre-release) version tags found")
-}
+	switch cfg.ReleaseTrain {
+	case "", "isoweek", "month":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("release train '%s' is not valid; must be (isoweek|month)", cfg.ReleaseTrain)
+	}
 
-func maybeVersionFromTag(tag string) (*version.Version, error) {
-	if tag == "" {
-		return nil, fmt.Errorf("empty tag not supported")
+	if cfg.TagMessageIncludePrevious && cfg.TagRefNamespace != "" && cfg.TagRefNamespace != defaultTagRefNamespace {
+		return fmt.Errorf("tag message include previous is only valid with the %s namespace", defaultTagRefNamespace)
 	}
 
-	ver, vErr := parseVersion(tag)
-	if vErr != nil {
-		return nil, fmt.Errorf("couldn't parse version %s: %s", tag, vErr)
+	if cfg.TagMessageIncludeIssues && cfg.TagRefNamespace != "" && cfg.TagRefNamespace != defaultTagRefNamespace {
+		return fmt.Errorf("tag message include issues is only valid with the %s namespace", defaultTagRefNamespace)
 	}
-	return ver, nil
-}
 
-// parseVersion returns a version object from a parsed string. This normalizes semver strings, and adds the ability to parse strings with 'v' leader. so that `v1.0.1`->     `1.0.1`  which we need for berkshelf to work
-func parseVersion(v string) (*version.Version, error) {
-	if versionRex.MatchString(v) {
-		m := versionRex.FindStringSubmatch(v)
-		if len(m) >= 2 {
-			v = m[1]
-		}
+	if cfg.Annotated && cfg.TagRefNamespace != "" && cfg.TagRefNamespace != defaultTagRefNamespace {
+		return fmt.Errorf("annotated is only valid with the %s namespace", defaultTagRefNamespace)
 	}
 
-	nVersion, err := version.NewVersion(v)
-	if err != nil && nVersion != nil && len(nVersion.Segments()) >= 1 {
-		return nVersion, err
+	if cfg.DryRunValidate && !cfg.DryRun {
+		return fmt.Errorf("dry run validate is only valid with dry run")
 	}
-	return nVersion, nil
-}
 
-// LatestVersion Reports the Latest version of the given repo
-// TODO:(jnelson) this could be more intelligent, looking for a nil new and reporting the latest version found if we refactor autobump at some point Mon Sep 14 13:05:49 2015
-func (r *GitRepo) LatestVersion() string {
-	return r.newVersion.String()
-}
+	if cfg.Sign && cfg.TagRefNamespace != "" && cfg.TagRefNamespace != defaultTagRefNamespace {
+		return fmt.Errorf("sign is only valid with the %s namespace", defaultTagRefNamespace)
+	}
 
-func (r *GitRepo) retrieveBranchInfo() error {
-	id, err := r.repo.BranchCommitID(r.branch)
-	if err != nil {
-		return fmt.Errorf("error getting head commit: %s ", err.Error())
+	if cfg.SigningKey != "" && !cfg.Sign {
+		return fmt.Errorf("signing key is only valid with sign")
 	}
 
-	r.branchID = id
-	return nil
-}
+	if cfg.DualTag && cfg.TagRefNamespace != "" && cfg.TagRefNamespace != defaultTagRefNamespace {
+		return fmt.Errorf("dual tag is only valid with the %s namespace", defaultTagRefNamespace)
+	}
 
-func preReleaseVersion(v, curPrereleaseVer *version.Version, name, tsLayout string, autoIncrease bool) (*version.Version, error) {
-	if len(name) == 0 && len(tsLayout) == 0 {
+	if cfg.ReleaseTagPrefix != "" && !cfg.DualTag {
+		return fmt.Errorf("release tag prefix is only valid with dual tag")
+	}
+
+	if cfg.TagMessage != "" && !cfg.Annotated && !cfg.Sign && !cfg.DualTag {
+		return fmt.Errorf("tag message is only valid with annotated, sign or dual tag")
+	}
+
+	if _, err := tagMessageText(cfg.TagMessage, tagMessageData{}); err != nil {
+		return err
+	}
+
+	for _, a := range cfg.VersionArtifacts {
+		if _, ok := artifactRexes[a.Format]; !ok {
+			return fmt.Errorf("version artifact format '%s' is not valid; must be (go|json|toml)", a.Format)
+		}
+		if a.Path == "" {
+			return fmt.Errorf("version artifact path must not be empty")
+		}
+	}
+
+	if cfg.BaseVersion != "" && cfg.BaseCommit == "" {
+		return fmt.Errorf("base version is only valid with base commit")
+	}
+
+	if cfg.BaseCommit != "" && cfg.BaseVersion == "" {
+		return fmt.Errorf("base commit is only valid with base version")
+	}
+
+	if cfg.BaseVersion != "" && (cfg.BaseTagLabel != "" || cfg.RequireSignedBaseTag || cfg.BumpFromTagAnnotation) {
+		return fmt.Errorf("base version cannot be combined with options reading the base tag")
+	}
+
+	if cfg.RequireSignedSuperseded && !cfg.RequireSignedBaseTag {
+		return fmt.Errorf("require signed superseded tags is only valid with require signed base tag")
+	}
+
+	if cfg.MaxPreReleaseNumber < 0 {
+		return fmt.Errorf("max pre-release number '%d' is not valid; must not be negative", cfg.MaxPreReleaseNumber)
+	}
+
+	if cfg.MaxSubjectLength < 0 {
+		return fmt.Errorf("max subject length '%d' is not valid; must not be negative", cfg.MaxSubjectLength)
+	}
+
+	switch cfg.PreReleaseTimestampLayout {
+	case "", "datetime", "epoch":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("pre-release-timestamp '%s' is not valid; must be (datetime|epoch)", cfg.PreReleaseTimestampLayout)
+	}
+
+	switch cfg.EmptyMessagePolicy {
+	case "", "patch", "skip", "error":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("empty message policy '%s' is not valid; must be (patch|skip|error)", cfg.EmptyMessagePolicy)
+	}
+
+	// the intermediate versions are plain bumps, a pre-release run must never publish a stable tag
+	if cfg.CreateIntermediateTags {
+		switch {
+		case cfg.PreReleaseName != "" || cfg.PreReleaseTimestampLayout != "":
+			return fmt.Errorf("create intermediate tags is not valid with a pre-release")
+		case cfg.BuildNumber || cfg.BuildMetadata != "" || len(cfg.MetadataFromEnv) > 0 || cfg.MetadataIncludeBranch || cfg.StableChannel != "":
+			return fmt.Errorf("create intermediate tags is not valid with build metadata")
+		case cfg.ReleaseTrain != "":
+			return fmt.Errorf("create intermediate tags is not valid with a release train")
+		}
+	}
+
+	for _, b := range cfg.BranchFallback {
+		if b == "" || checkRefFormat("refs/heads/"+b) != nil {
+			return fmt.Errorf("fallback branch '%s' is not a valid branch name", b)
+		}
+	}
+
+	if cfg.BumpTrailerKey != "" && !trailerKeyRex.MatchString(cfg.BumpTrailerKey) {
+		return fmt.Errorf("bump trailer key '%s' is not valid; must be letters, digits and hyphens", cfg.BumpTrailerKey)
+	}
+
+	if cfg.PathScope != "" {
+		if p := path.Clean(filepath.ToSlash(cfg.PathScope)); path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("path scope '%s' must be a path inside the repository", cfg.PathScope)
+		}
+	}
+
+	if cfg.BumpNotesRef != "" && checkRefFormat(notesRef(cfg.BumpNotesRef)) != nil {
+		return fmt.Errorf("bump notes ref '%s' is not a valid git ref", cfg.BumpNotesRef)
+	}
+
+	switch cfg.PreReleaseStabilizedPolicy {
+	case "", "bump-base", "error":
+		// nothing -- valid values
+	default:
+		return fmt.Errorf("pre-release stabilized policy '%s' is not valid; must be (bump-base|error)", cfg.PreReleaseStabilizedPolicy)
+	}
+
+	if cfg.FourSegment && cfg.VersionStrategy != nil {
+		return fmt.Errorf("four segment versions cannot be enabled with a custom version strategy")
+	}
+
+	if cfg.StrictPreReleaseOrdering && cfg.PreReleaseName != "" && cfg.PreReleaseNumber && cfg.PreReleaseTimestampLayout == "" {
+		strategy := cfg.VersionStrategy
+		if strategy == nil {
+			strategy = SemVerStrategy{}
+		}
+		if err := checkPreReleaseOrdering(cfg.PreReleaseName, strategy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkPreReleaseOrdering generates a run of numbered pre-release versions, crossing from one to two
+// digit numbers, and checks that each sorts after the one before it.
+func checkPreReleaseOrdering(name string, strategy VersionStrategy) error {
+	base, err := version.NewVersion("1.0.0")
+	if err != nil {
+		return err
+	}
+
+	var prev *version.Version
+	for i := 0; i < 11; i++ {
+		next, err := preReleaseVersion(base, prev, name, "", true)
+		if err != nil {
+			return fmt.Errorf("pre-release name '%s' is not valid: %s", name, err)
+		}
+		if prev != nil && strategy.Compare(next, prev) <= 0 {
+			return fmt.Errorf("pre-release name '%s' does not sort in order; '%s' is not after '%s'", name, next, prev)
+		}
+		prev = next
+	}
+	return nil
+}
+
+// isBareRepo reports whether path looks like a git directory, ie: a bare or mirror clone
+func isBareRepo(path string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateGitDirPath returns the git directory of the repository at repoPath. In a linked worktree
+// `.git` is a file pointing at the real git directory, eg: `gitdir: /repo/.git/worktrees/name`. A bare
+// or mirror clone is its own git directory.
+func generateGitDirPath(repoPath string) (string, error) {
+	absolutePath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", err
+	}
+
+	gitDirPath := filepath.Join(absolutePath, ".git")
+	info, err := os.Stat(gitDirPath)
+	if os.IsNotExist(err) && isBareRepo(absolutePath) {
+		return absolutePath, nil
+	}
+	if err != nil || info.IsDir() {
+		return gitDirPath, nil
+	}
+
+	data, err := os.ReadFile(gitDirPath)
+	if err != nil {
+		return "", err
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("'%s' is not a valid .git file; missing gitdir", gitDirPath)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(absolutePath, dir)
+	}
+	return dir, nil
+}
+
+// applyGitConfigDefaults fills the scheme, tag prefix and pre-release name from the [autotag] section
+// of the repo's git config, eg: `git config autotag.scheme conventional`, when they are not set in cfg.
+// AUTOTAG_SCHEME, already applied to cfg, takes precedence over autotag.scheme.
+func applyGitConfigDefaults(gitDirPath string, cfg *GitRepoConfig) error {
+	// exits non-zero when no key matches, which is the same as an empty section
+	out, err := git.NewCommand("config", "--get-regexp", `^autotag\.`).RunInDir(gitDirPath)
+	if err != nil {
+		return nil
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		// git reports keys in lowercase
+		switch key {
+		case gitConfigScheme:
+			if cfg.Scheme != "" {
+				continue
+			}
+			if !validScheme(value) {
+				return fmt.Errorf("git config %s '%s' is not valid; must be (autotag|conventional)", key, value)
+			}
+			cfg.Scheme = value
+		case gitConfigPrefix:
+			if cfg.TagPrefix != "" {
+				continue
+			}
+			cfg.TagPrefix = value
+		case gitConfigPreReleaseName:
+			if cfg.PreReleaseName != "" {
+				continue
+			}
+			cfg.PreReleaseName = value
+		default:
+			continue
+		}
+		log.Printf("using git config %s '%s'", key, value)
+	}
+	return nil
+}
+
+// Parse tags on repo, sort them, and store the most recent revision in the repo object. The sorted
+// versions are walked from the highest down only until the base is found, the pre-release counter
+// can only be a pre-release above the base, and the base tag is the only commit read.
+func (r *GitRepo) parseTags() error {
+	log.Println("Parsing repository tags")
+
+	versions := make(map[*version.Version]tagRef)
+
+	tags, err := r.listTags()
+	if err != nil {
+		return fmt.Errorf("failed to fetch tags: %s", err.Error())
+	}
+
+	ignored, err := r.readIgnoreTags()
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if matchesAny(ignored, tag.name) {
+			log.Println("skipping ignored tag: ", tag.name)
+			r.stats.TagsSkipped++
+			continue
+		}
+		if r.pathScope != "" && !r.tagPrefixRex.MatchString(tag.name) {
+			log.Println("skipping tag outside of the path scope: ", tag.name)
+			r.stats.TagsSkipped++
+			continue
+		}
+		name := r.stripTagPrefix(tag.name)
+		if r.floatingAliases && aliasRex.MatchString(name) {
+			log.Println("skipping alias tag: ", tag.name)
+			r.stats.TagsSkipped++
+			continue
+		}
+		if r.legacySeparators {
+			name = normalizeLegacySeparators(name)
+		}
+		v, err := maybeVersionFromTag(name, r.strategy)
+		if err != nil || v == nil {
+			log.Println("skipping non version tag: ", tag.name)
+			r.stats.TagsSkipped++
+			continue
+		}
+
+		versions[v] = tag
+		r.stats.TagsParsed++
+	}
+
+	keys := make([]*version.Version, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	// versions differing only in build metadata have the same precedence, the most recently created
+	// tag wins the tie, then the tag name so the order never depends on the map iteration order
+	sort.Slice(keys, func(i, j int) bool {
+		if c := r.compareTagVersions(keys[i], keys[j]); c != 0 {
+			return c > 0
+		}
+		if ci, cj := versions[keys[i]].created, versions[keys[j]].created; ci != cj {
+			return ci > cj
+		}
+		return versions[keys[i]].name > versions[keys[j]].name
+	})
+
+	tagNames := make(map[*version.Version]string, len(keys))
+	for _, v := range keys {
+		t := versions[v]
+		tagNames[v] = t.name
+		r.tags = append(r.tags, TagInfo{Name: t.name, Version: v, SHA: t.sha, Date: t.date})
+		if t.created > r.lastReleased {
+			r.lastReleased = t.created
+		}
+	}
+	r.checkPreReleaseMetadata(keys, tagNames)
+
+	// once a stable version is tagged after the newest reset commit, the tags before it are ignored
+	resetTags, err := r.resetBaseTags()
+	if err != nil {
+		return err
+	}
+	var resetBase bool
+	for _, v := range keys {
+		if resetTags[tagNames[v]] && v.Prerelease() == "" {
+			resetBase = true
+			break
+		}
+	}
+
+	// stamps the tag the next version is calculated from
+	setBase := func(v *version.Version) error {
+		if r.requireSignedBaseTag {
+			if err := r.verifyTag(tagNames[v]); err != nil {
+				return err
+			}
+			// the tags sorting above the base are ignored, an unsigned one may have been tampered with
+			var unsigned []string
+			for _, k := range keys {
+				if k == v {
+					break
+				}
+				if err := r.verifyTag(tagNames[k]); err != nil {
+					unsigned = append(unsigned, tagNames[k])
+				}
+			}
+			if len(unsigned) > 0 {
+				if r.requireSignedSuperseded {
+					return fmt.Errorf("tags superseded by base tag '%s' could not be verified: %s", tagNames[v], strings.Join(unsigned, ", "))
+				}
+				r.warn("tags superseded by base tag '%s' could not be verified: %s", tagNames[v], strings.Join(unsigned, ", "))
+			}
+		}
+		// only the base tag's commit is read, the others are never needed
+		c, err := r.repo.CommitByRevision(r.tagRef(tagNames[v]))
+		if err != nil {
+			return fmt.Errorf("error reading commit '%s':  %s", tagNames[v], err)
+		}
+		r.currentVersion = v
+		r.currentTag = c
+		r.currentTagName = tagNames[v]
+		return nil
+	}
+
+	// a tag labeled as the release base is used instead of the latest stable tag
+	var labeledBase *version.Version
+	if r.baseTagLabel != "" {
+		labeled, err := r.labeledTags()
+		if err != nil {
+			return fmt.Errorf("failed to read tag messages: %s", err.Error())
+		}
+		for _, v := range keys {
+			if labeled[tagNames[v]] {
+				labeledBase = v
+				break
+			}
+		}
+		if labeledBase == nil {
+			log.Printf("no tag labeled '%s' found, using the latest stable tag", r.baseTagLabel)
+		}
+	}
+
+	// loop over the tags and find the last reachable non pre-release tag,
+	// because we want to calculate the tag from v1.2.3 not v1.2.4-pre1.`
+	for i, version := range keys {
+		// stamps latest tag
+		if i == 0 {
+			r.latestTagVersion = version
+		}
+
+		// stamps latest tag for pre-release
+		if r.preReleaseName != "" && version.Prerelease() != "" && r.curPreReleaseVer == nil {
+			if strings.HasPrefix(version.Prerelease(), fmt.Sprintf("%s.", r.preReleaseName)) {
+				r.curPreReleaseVer = version
+			}
+		}
+
+		// the explicit base version replaces the tags, which are only read for the fields above
+		if r.baseVersion != "" {
+			continue
+		}
+
+		if resetBase && !resetTags[tagNames[version]] {
+			log.Printf("skipping tag version before the reset commit: %s", version.String())
+			continue
+		}
+
+		if labeledBase != nil {
+			if version == labeledBase {
+				return setBase(version)
+			}
+			log.Printf("skipping unlabeled tag version: %s", version.String())
+			continue
+		}
+
+		if len(version.Prerelease()) == 0 {
+			if r.stableChannel == "" || hasMetadataIdentifier(version, r.stableChannel) {
+				return setBase(version)
+			}
+			log.Printf("skipping unmarked stable tag version: %s", version.String())
+			continue
+		}
+		log.Printf("skipping pre-release tag version: %s", version.String())
+	}
+
+	if r.baseVersion != "" {
+		return r.setBaseVersion()
+	}
+
+	if r.allowPreReleaseBase && len(keys) > 0 {
+		log.Printf("no stable version tags found, using pre-release tag version: %s", keys[0].String())
+		return setBase(keys[0])
+	}
+
+	return fmt.Errorf("no stable (non pre-release) version tags found")
+}
+
+// setBaseVersion uses the BaseVersion released at BaseCommit as the base, instead of a tag
+func (r *GitRepo) setBaseVersion() error {
+	v, err := r.strategy.Parse(r.baseVersion)
+	if err != nil || v == nil {
+		return fmt.Errorf("base version '%s' is not a valid version", r.baseVersion)
+	}
+	c, err := r.repo.CommitByRevision(r.baseCommit)
+	if err != nil {
+		return fmt.Errorf("error reading base commit '%s': %s", r.baseCommit, err)
+	}
+	log.Printf("Using base version %s at commit %s", v, c.ID)
+	r.currentVersion = v
+	r.currentTag = c
+	if r.latestTagVersion == nil {
+		r.latestTagVersion = v
+	}
+	return nil
+}
+
+// stabilizedPreRelease returns the latest pre-release tag in the PreReleaseName channel of the stable
+// base version, eg: `v1.0.2-dev.1` for the base `v1.0.2`, or "" if there is none
+func (r *GitRepo) stabilizedPreRelease() string {
+	if r.preReleaseName == "" || r.currentVersion.Prerelease() != "" {
+		return ""
+	}
+	for _, t := range r.tags {
+		pr := t.Version.Prerelease()
+		if (pr == r.preReleaseName || strings.HasPrefix(pr, r.preReleaseName+".")) && t.Version.Core().Equal(r.currentVersion.Core()) {
+			return t.Name
+		}
+	}
+	return ""
+}
+
+// compareTagVersions orders the tag versions with the VersionStrategy, except that pre-releases of the
+// same version are first ordered by their channel in ChannelOrder. The channels not listed rank as one
+// group below the listed ones, so the order stays transitive and the sort never depends on the input.
+func (r *GitRepo) compareTagVersions(a, b *version.Version) int {
+	if len(r.channelOrder) > 0 && a.Prerelease() != "" && b.Prerelease() != "" && a.Core().Equal(b.Core()) {
+		ca, _, _ := strings.Cut(a.Prerelease(), ".")
+		cb, _, _ := strings.Cut(b.Prerelease(), ".")
+		// unlisted channels are -1
+		if ia, ib := channelIndex(r.channelOrder, ca), channelIndex(r.channelOrder, cb); ia != ib {
+			return ia - ib
+		}
+	}
+	return r.strategy.Compare(a, b)
+}
+
+// hasMetadataIdentifier reports whether one of the dot separated build metadata identifiers is id
+func hasMetadataIdentifier(v *version.Version, id string) bool {
+	for _, m := range strings.Split(v.Metadata(), ".") {
+		if m == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreReleaseMetadata warns when the pre-release name is used as build metadata by one of the
+// recent tags, eg: `1.2.3+rc` when the pre-release name is `rc`. Build metadata is ignored by
+// precedence, so the pre-releases created would not sort the way the existing tags suggest.
+func (r *GitRepo) checkPreReleaseMetadata(keys []*version.Version, tagNames map[*version.Version]string) {
+	if r.preReleaseName == "" {
+		return
+	}
+	for i, v := range keys {
+		if i == recentTagsChecked {
+			break
+		}
+		if hasMetadataIdentifier(v, r.preReleaseName) {
+			r.warn("pre-release name '%s' is used as build metadata by tag '%s', it may be misconfigured", r.preReleaseName, tagNames[v])
+			return
+		}
+	}
+}
+
+// warn logs a warning and records it for Warnings
+func (r *GitRepo) warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Println("warning:", msg)
+	r.warnings = append(r.warnings, msg)
+}
+
+// channelIndex returns the position of the channel in the order, or -1 if it is not listed
+func channelIndex(order []string, channel string) int {
+	for i, c := range order {
+		if c == channel {
+			return i
+		}
+	}
+	return -1
+}
+
+// readIgnoreTags returns the globs listed in the .autotag-ignore-tags file, one per line, eg:
+// `experiment-*`. Blank lines and lines starting with # are ignored. A missing file ignores nothing.
+func (r *GitRepo) readIgnoreTags() ([]string, error) {
+	data, err := os.ReadFile(r.ignoreTagsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("%s pattern '%s' is not valid: %s", ignoreTagsFileName, line, err)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// matchesAny reports whether the name matches any of the globs, which must be valid
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// tagRef is a tag read by listTags, with its tagged commit resolved in the same git call
+type tagRef struct {
+	name string
+
+	// sha is the ID of the tagged commit, peeled through an annotated tag
+	sha string
+
+	// date is the author or committer date of the tagged commit, see DateSource
+	date time.Time
+
+	// created is the creation time of the tag as a unix timestamp: the tagger date for annotated
+	// tags and the commit date, see DateSource, for lightweight tags
+	created int64
+}
+
+// listTags returns the tags stored under the configured tag ref namespace. A single for-each-ref
+// call resolves every tag, rather than one git call per tag, which matters with thousands of tags.
+func (r *GitRepo) listTags() ([]tagRef, error) {
+	date, created := "authordate", "%(if)%(taggerdate)%(then)%(taggerdate:unix)%(else)%(authordate:unix)%(end)"
+	if r.dateSource == "committer" {
+		date, created = "committerdate", "%(creatordate:unix)"
+	}
+	format := "--format=%(refname)%00" +
+		"%(if)%(*objectname)%(then)%(*objectname)%(else)%(objectname)%(end)%00" +
+		"%(if)%(*objectname)%(then)%(*" + date + ":unix)%(else)%(" + date + ":unix)%(end)%00" +
+		created
+
+	out, err := git.NewCommand("for-each-ref", format, r.tagRefNamespace+"/").RunInDir(r.repo.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []tagRef
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 4 {
+			continue
+		}
+		t := tagRef{name: strings.TrimPrefix(fields[0], r.tagRefNamespace+"/"), sha: fields[1]}
+		// tags of trees or blobs have no commit date
+		if fields[2] != "" {
+			ts, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date '%s' for '%s'", fields[2], fields[0])
+			}
+			t.date = time.Unix(ts, 0)
+		}
+		if fields[3] != "" {
+			if t.created, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid date '%s' for '%s'", fields[3], fields[0])
+			}
+		}
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+// labeledTags returns the names of the annotated tags with a line in their message matching the
+// BaseTagLabel.
+func (r *GitRepo) labeledTags() (map[string]bool, error) {
+	// NUL separated records of "<objecttype> <refname>" and the tag message
+	out, err := git.NewCommand("for-each-ref", "--format=%00%(objecttype) %(refname)%00%(contents)", r.tagRefNamespace+"/").RunInDir(r.repo.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	labeled := make(map[string]bool)
+	fields := strings.Split(string(out), "\x00")
+	for i := 1; i+1 < len(fields); i += 2 {
+		objectType, ref, _ := strings.Cut(fields[i], " ")
+		if objectType != "tag" {
+			continue
+		}
+		for _, line := range strings.Split(fields[i+1], "\n") {
+			if strings.TrimSpace(line) == r.baseTagLabel {
+				labeled[strings.TrimPrefix(ref, r.tagRefNamespace+"/")] = true
+				break
+			}
+		}
+	}
+	return labeled, nil
+}
+
+// commitDate returns the author or committer date of the commit, see DateSource
+func (r *GitRepo) commitDate(c *git.Commit) time.Time {
+	if r.dateSource == "committer" {
+		return c.Committer.When
+	}
+	return c.Author.When
+}
+
+// tagRef returns the full reference of a tag name within the configured tag ref namespace.
+func (r *GitRepo) tagRef(name string) string {
+	return r.tagRefNamespace + "/" + name
+}
+
+// verifyTag checks the signature of an annotated tag using `git verify-tag`.
+func (r *GitRepo) verifyTag(name string) error {
+	if _, err := git.NewCommand("verify-tag", r.tagRef(name)).RunInDir(r.repo.Path()); err != nil {
+		return fmt.Errorf("base tag '%s' signature could not be verified: %s", name, err)
+	}
+	return nil
+}
+
+// stripTagPrefix removes the configured TagPrefix from the start of a tag name, if present.
+func (r *GitRepo) stripTagPrefix(tag string) string {
+	if r.tagPrefixRex == nil {
+		return tag
+	}
+	return r.tagPrefixRex.ReplaceAllString(tag, "")
+}
+
+// tagPrefixRegexp builds a regexp matching the TagPrefix at the start of a tag name, where the
+// {date} placeholder matches any YYYYMMDD date.
+func tagPrefixRegexp(prefix string) *regexp.Regexp {
+	parts := strings.Split(prefix, "{date}")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, `\d{8}`))
+}
+
+func maybeVersionFromTag(tag string, strategy VersionStrategy) (*version.Version, error) {
+	if tag == "" {
+		return nil, fmt.Errorf("empty tag not supported")
+	}
+
+	ver, vErr := strategy.Parse(tag)
+	if vErr != nil {
+		return nil, fmt.Errorf("couldn't parse version %s: %s", tag, vErr)
+	}
+	return ver, nil
+}
+
+// normalizeLegacySeparators replaces `_` and `-` between the numeric segments of a version with `.`,
+// eg: `v1_2_3-rc1` -> `v1.2.3-rc1`. Any pre-release or metadata after the numeric part is left alone.
+func normalizeLegacySeparators(tag string) string {
+	core := legacyVersionCoreRex.FindString(tag)
+	if core == "" {
+		return tag
+	}
+	return strings.NewReplacer("_", ".", "-", ".").Replace(core) + tag[len(core):]
+}
+
+// parseVersion returns a version object from a parsed string. This normalizes semver strings, and adds the ability to parse strings with 'v' leader. so that `v1.0.1`->     `1.0.1`  which we need for berkshelf to work
+func parseVersion(v string) (*version.Version, error) {
+	if versionRex.MatchString(v) {
+		m := versionRex.FindStringSubmatch(v)
+		if len(m) >= 2 {
+			v = m[1]
+		}
+	}
+
+	nVersion, err := version.NewVersion(v)
+	if err != nil && nVersion != nil && len(nVersion.Segments()) >= 1 {
+		return nVersion, err
+	}
+	return nVersion, nil
+}
+
+// LatestVersion Reports the Latest version of the given repo
+// TODO:(jnelson) this could be more intelligent, looking for a nil new and reporting the latest version found if we refactor autobump at some point Mon Sep 14 13:05:49 2015
+func (r *GitRepo) LatestVersion() string {
+	return r.strategy.Format(r.newVersion)
+}
+
+// CreatedRef reports the full ref of the tag created by AutoTag, eg: `refs/tags/v1.2.3`, for
+// subsequent ref operations such as pushing it. It is empty until the tag is created.
+func (r *GitRepo) CreatedRef() string {
+	return r.createdRef
+}
+
+// Warnings reports the likely misconfigurations noticed while reading the repository, eg: a
+// pre-release name that recent tags use as build metadata. They do not stop a release.
+func (r *GitRepo) Warnings() []string {
+	return r.warnings
+}
+
+// EffectiveConfig returns the configuration in effect after defaulting and discovery, eg: the scheme
+// from $AUTOTAG_SCHEME, the tag prefix from git config or the detected branch, to confirm where a
+// setting came from when autotag behaves unexpectedly. Slices and maps are shared with the repo.
+func (r *GitRepo) EffectiveConfig() GitRepoConfig {
+	cfg := r.cfg
+	if cfg.TagFormatter == nil {
+		cfg.TagFormatter = DefaultTagFormatter
+	}
+	return cfg
+}
+
+// GoModuleVersion reports the new version in the form used by the Go toolchain, eg: `v1.2.3`. Build
+// metadata is not allowed in Go module versions and is dropped. With GoModuleCompat enabled, versions
+// with a major version of 2 or more get the `+incompatible` suffix, eg: `v2.0.0+incompatible`.
+func (r *GitRepo) GoModuleVersion() string {
+	v := "v" + r.newVersion.Core().String()
+	if pre := r.newVersion.Prerelease(); pre != "" {
+		v += "-" + pre
+	}
+	if r.goModuleCompat && r.newVersion.Segments()[0] >= 2 {
+		v += "+incompatible"
+	}
+	return v
+}
+
+// MarketingVersion reports the new version truncated to the given number of segments, 1 to 3,
+// without pre-release or build metadata, eg: `1.2` for 1.2.3-rc.1+build.5 with 2 segments, for
+// surfaces showing a simplified version. Values outside the range are clamped.
+func (r *GitRepo) MarketingVersion(segments int) string {
+	if segments < 1 {
+		segments = 1
+	}
+	if segments > 3 {
+		segments = 3
+	}
+	s := r.newVersion.Segments()
+	parts := make([]string, segments)
+	for i := range parts {
+		parts[i] = strconv.Itoa(s[i])
+	}
+	return strings.Join(parts, ".")
+}
+
+// DockerTag reports the new version as a Docker image tag: lowercase and with the `+` before build
+// metadata replaced by the DockerTagSeparator, eg: `1.2.3-RC.1+Build.5` -> `1.2.3-rc.1_build.5`.
+func (r *GitRepo) DockerTag() string {
+	return strings.ToLower(strings.ReplaceAll(r.LatestVersion(), "+", r.dockerTagSeparator))
+}
+
+// BumpMessage reports a short human readable summary of the calculated bump, eg:
+// `Bumping v1.2.2 → v1.2.3 (patch)`. Pre-release and build metadata are included as
+// they will appear in the tag.
+func (r *GitRepo) BumpMessage() string {
+	bump := r.bumpName()
+	current := r.currentTagName
+	if current == "" {
+		current = r.displayTagName(r.currentVersion)
+	}
+
+	if bump == "none" {
+		return fmt.Sprintf("No version bump from %s", current)
+	}
+	return fmt.Sprintf("Bumping %s → %s (%s)", current, r.displayTagName(r.newVersion), bump)
+}
+
+// BumpType reports the bump the new version was calculated with, eg: BumpMinor for a `[minor]` commit.
+// It is the bump decided from the commits or the bump file, also when a pre-release, release train or
+// pre-release base changes the version otherwise. It is BumpNone when nothing is bumped, eg: when a
+// reset commit sets the version.
+func (r *GitRepo) BumpType() BumpType {
+	if r.bump == nil {
+		return BumpNone
+	}
+	return BumpType(fmt.Sprint(r.bump))
+}
+
+// PreviousRunBump reports the highest bump of the commits since the previous run recorded in the
+// StateFile, BumpNone without state or new commits. It does not change the new version, which is
+// always calculated from the base tag.
+func (r *GitRepo) PreviousRunBump() BumpType {
+	if r.previousRunBump == nil {
+		return BumpNone
+	}
+	return BumpType(fmt.Sprint(r.previousRunBump))
+}
+
+// bumpName is the name of the BumpType, the single notion of the bump used by Stats, BumpMessage,
+// WriteSummary and the manifest.
+func (r *GitRepo) bumpName() string {
+	return string(r.BumpType())
+}
+
+// tagName formats a version as a tag name with the configured TagFormatter, by default prepending
+// the TagPrefix (with {date} expanded) or 'v' when prefix is enabled.
+func (r *GitRepo) tagName(v *version.Version) (string, error) {
+	if r.tagFormatter == nil {
+		return r.tagNamePrefix() + r.strategy.Format(v), nil
+	}
+	// the formatter sees the date of the run, not the time it is called
+	cfg := r.cfg
+	cfg.TagPrefix = strings.ReplaceAll(cfg.TagPrefix, "{date}", r.tagDate)
+	name, err := r.tagFormatter(v, cfg)
+	if err != nil {
+		return "", fmt.Errorf("error formatting the tag name of '%s': %s", v, err)
+	}
+	return name, nil
+}
+
+// displayTagName is the tag name of a version for reports, falling back to the version if the
+// TagFormatter fails
+func (r *GitRepo) displayTagName(v *version.Version) string {
+	name, err := r.tagName(v)
+	if err != nil {
+		return r.strategy.Format(v)
+	}
+	return name
+}
+
+// tagNamePrefix returns the string prepended to versions in new tag names
+func (r *GitRepo) tagNamePrefix() string {
+	return formatTagPrefix(r.tagPrefix, r.prefix, r.tagDate)
+}
+
+// TagFormatter returns the tag name of a version, given the configuration in effect. See
+// GitRepoConfig.TagFormatter.
+type TagFormatter func(v *version.Version, cfg GitRepoConfig) (string, error)
+
+// DefaultTagFormatter is the TagFormatter used if none is configured: the version formatted by the
+// VersionStrategy, prefixed by the TagPrefix with {date} expanded, or 'v' when Prefix is enabled. It
+// can be wrapped by custom formatters.
+func DefaultTagFormatter(v *version.Version, cfg GitRepoConfig) (string, error) {
+	strategy := cfg.VersionStrategy
+	if strategy == nil {
+		strategy = SemVerStrategy{}
+	}
+	return formatTagPrefix(cfg.TagPrefix, cfg.Prefix, timeNow().UTC().Format(tagPrefixDateLayout)) + strategy.Format(v), nil
+}
+
+// formatTagPrefix returns the TagPrefix with {date} expanded to the date, or 'v' when prefix is enabled
+func formatTagPrefix(tagPrefix string, prefix bool, date string) string {
+	if tagPrefix != "" {
+		return strings.ReplaceAll(tagPrefix, "{date}", date)
+	}
+	if !prefix {
+		return ""
+	}
+	return "v"
+}
+
+// findBranch returns the first of the branches that exists locally, or else the first that exists as a
+// remote-tracking branch, eg: when a clone in CI only has remote-tracking branches. It returns "" if
+// none exists.
+func findBranch(repo *git.Repository, branches []string) string {
+	// `git show-ref --heads` fails when there are no local branches at all
+	local, err := repo.Branches()
+	if err != nil {
+		log.Println("no local branches found:", err)
+	}
+	for _, b := range branches {
+		for _, l := range local {
+			if l == b {
+				return b
+			}
+		}
+	}
+	for _, b := range branches {
+		if _, err := remoteBranchID(repo, b); err == nil {
+			return b
+		}
+	}
+	return ""
+}
+
+// remoteBranchID returns the commit id of a remote-tracking branch with the given name, eg:
+// refs/remotes/origin/main for main. It is used when there is no local branch, such as in a mirror.
+// A name including the remote, eg: origin/main, selects that remote-tracking branch.
+func remoteBranchID(repo *git.Repository, branch string) (string, error) {
+	patterns := []string{"refs/remotes/*/" + branch}
+	if strings.Contains(branch, "/") {
+		patterns = append([]string{"refs/remotes/" + branch}, patterns...)
+	}
+
+	for _, pattern := range patterns {
+		out, err := git.NewCommand("for-each-ref", "--count=1", "--format=%(refname) %(objectname)", pattern).RunInDir(repo.Path())
+		if err != nil {
+			return "", err
+		}
+		// for-each-ref also matches refs nested below the pattern, eg: origin/main/foo
+		ref, id, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+		if id != "" && (ref == pattern || strings.Contains(pattern, "*")) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no remote-tracking branch '%s' found", branch)
+}
+
+func (r *GitRepo) retrieveBranchInfo() error {
+	id, err := r.repo.BranchCommitID(r.branch)
+	if err != nil {
+		if rid, rerr := remoteBranchID(r.repo, r.branch); rerr == nil {
+			log.Printf("Using remote-tracking branch for '%s'", r.branch)
+			r.branchID = rid
+			return nil
+		}
+
+		// an unborn branch is checked out (HEAD points at it) but has no ref until the first commit
+		if head, herr := r.repo.SymbolicRef(); herr == nil && head == "refs/heads/"+r.branch {
+			return fmt.Errorf("branch '%s': %w", r.branch, ErrEmptyBranch)
+		}
+		return fmt.Errorf("error getting head commit: %s ", err.Error())
+	}
+
+	r.branchID = id
+	return nil
+}
+
+func preReleaseVersion(v, curPrereleaseVer *version.Version, name, tsLayout string, autoIncrease bool) (*version.Version, error) {
+	if len(name) == 0 && len(tsLayout) == 0 {
 		return v, nil
 	}
-
-	if len(v.Prerelease()) > 0 {
-		return nil, errors.New("*version.Version already has a PreRelease value set")
+
+	if len(v.Prerelease()) > 0 {
+		return nil, errors.New("*version.Version already has a PreRelease value set")
+	}
+
+	buf := &bytes.Buffer{}
+
+	if _, err := buf.WriteString(name); err != nil {
+		return nil, err
+	}
+
+	if len(tsLayout) > 0 {
+		// XXX(theckman): if the buffer already has content written to it, add
+		// the `.` character as a delimiter. The `+` character was not used as
+		// the delimiter because some systems that support version tags do not
+		// allow it within the string (looking at you, Docker).
+		if buf.Len() > 0 {
+			if _, err := buf.WriteString("."); err != nil {
+				return nil, err
+			}
+		}
+
+		var (
+			timestamp   string
+			currentTime = timeNow().UTC()
+		)
+
+		if tsLayout == "epoch" {
+			timestamp = strconv.FormatInt(currentTime.Unix(), 10)
+		} else {
+			timestamp = currentTime.Format(tsLayout)
+		}
+
+		if _, err := buf.WriteString(timestamp); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(name) > 0 && autoIncrease {
+			// Write the `.` character
+			if buf.Len() > 0 {
+				if _, err := buf.WriteString("."); err != nil {
+					return nil, err
+				}
+			}
+
+			prereleaseNumber := "1"
+			if curPrereleaseVer != nil {
+				prerelease := curPrereleaseVer.Prerelease()
+				prereleaseParts := strings.Split(prerelease, ".")
+				if len(prereleaseParts) == 2 {
+					currentPrereleaseNumber, err := strconv.ParseUint(prereleaseParts[1], 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("prerelease build number must be a unsigned integer")
+					}
+
+					prereleaseNumber = strconv.FormatUint(currentPrereleaseNumber+1, 10)
+				}
+			}
+
+			if _, err := buf.WriteString(prereleaseNumber); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	verStr := fmt.Sprintf("%s-%s", v.String(), buf.String())
+	return version.NewVersion(verStr)
+}
+
+// readBumpFile returns the bump recorded in the .autotag-bump file, and whether the file exists. A
+// nil bumper with ok set means `none`.
+func (r *GitRepo) readBumpFile() (b bumper, ok bool, err error) {
+	data, err := os.ReadFile(r.bumpFilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "none" {
+		return nil, true, nil
+	}
+	if b, ok := namedBumpers[value]; ok {
+		return b, true, nil
+	}
+	return nil, false, fmt.Errorf("%s value '%s' is not valid; must be (major|minor|patch|none)", bumpFileName, value)
+}
+
+// preReleaseCounter returns the pre-release number of a version created with PreReleaseNumber, eg: 3
+// for 1.0.0-rc.3.
+func preReleaseCounter(v *version.Version) (uint64, bool) {
+	parts := strings.Split(v.Prerelease(), ".")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(parts[1], 10, 64)
+	return n, err == nil
+}
+
+// bumpFromCommits calculates the new version from the messages of the commits since the current tag
+func (r *GitRepo) bumpFromCommits() error {
+	start := r.currentTag.ID.String()
+	revList := []string{fmt.Sprintf("%s..%s", start, r.branchID)}
+
+	l, err := r.revList(revList...)
+	if len(l) == 0 && (r.strictMatch || r.skipWhenNothingToTag) {
+		return ErrNothingToTag
+	}
+	if err != nil {
+		log.Printf("Error loading history for tag '%s': %s ", r.currentVersion, err.Error())
+	}
+
+	// the newest reset commit restarts the history, only the commits after it are checked. The base is
+	// only before it until the reset is released, see resetBaseTags.
+	for i, commit := range l {
+		v, err := r.resetVersion(commit)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+		log.Printf("Commit %s resets the version to %s", commit.ID, v)
+		r.currentVersion, r.newVersion = v, v
+		if i == 0 {
+			// the reset commit is the branch head and is tagged with the reset version
+			return nil
+		}
+		l = l[:i]
+		start = commit.ID.String()
+		break
+	}
+
+	// r.branchID is the newest commit; start is oldest
+	log.Printf("Checking commits from %s to %s ", r.branchID, start)
+
+	// the commits since the previous run are only reported, the version is bumped from the base tag
+	var sincePreviousRun map[string]bool
+	if r.rangeStart != "" {
+		log.Printf("Reporting the commits since the previous run at %s", r.rangeStart)
+		since, err := r.revList(fmt.Sprintf("%s..%s", r.rangeStart, r.branchID))
+		if err != nil {
+			return err
+		}
+		sincePreviousRun = make(map[string]bool, len(since))
+		for _, commit := range since {
+			sincePreviousRun[commit.ID.String()] = true
+		}
+	}
+
+	// the notes override the message, so they are read before the commits are filtered by it
+	if r.bumpNotesRef != "" {
+		if r.bumpNotes, err = r.readBumpNotes(l); err != nil {
+			return err
+		}
+	}
+
+	// Revlist returns in reverse Chronological We want chronological. Then check each commit for bump messages
+	commits := make([]*git.Commit, 0, len(l))
+	for i := len(l) - 1; i >= 0; i-- {
+		commit := l[i] // getting the reverse order element
+		if commit == nil {
+			return fmt.Errorf("commit pointed to nil object. This should not happen")
+		}
+		r.stats.CommitsScanned++
+
+		if date := r.commitDate(commit); r.rejectFuture && date.After(timeNow().Add(futureCommitTolerance)) {
+			return fmt.Errorf("commit %s is dated in the future: %s", commit.ID, date.UTC().Format(time.RFC3339))
+		}
+
+		if r.skipMergeCommits && commit.ParentsCount() > 1 {
+			log.Printf("Skipping merge commit %s", commit.ID)
+			continue
+		}
+		if strings.TrimSpace(commit.Message) == "" && !bumpNoteRex.MatchString(r.bumpNotes[commit.ID.String()]) {
+			switch r.emptyMessagePolicy {
+			case "skip":
+				log.Printf("Skipping commit %s with an empty message", commit.ID)
+				continue
+			case "error":
+				return fmt.Errorf("commit %s has an empty message", commit.ID)
+			}
+		}
+		if r.ignoreSubmodules {
+			ok, err := r.submoduleUpdate(commit)
+			if err != nil {
+				return err
+			}
+			if ok {
+				log.Printf("Skipping submodule update commit %s", commit.ID)
+				continue
+			}
+		}
+		commits = append(commits, commit)
+	}
+
+	// each commit is matched independently, the bumps are applied in order
+	for i, p := range r.matchCommits(commits) {
+		commit := commits[i]
+		v, nerr := r.applyCommitMatch(commit, p)
+		if nerr != nil {
+			return nerr
+		}
+		if sincePreviousRun[commit.ID.String()] {
+			r.stats.CommitsSincePreviousRun++
+			if bumpRank(p.b) > bumpRank(r.previousRunBump) {
+				r.previousRunBump = p.b
+			}
+		}
+
+		if v != nil && r.strategy.Compare(v, r.newVersion) > 0 {
+			r.newVersion = v
+			if r.createIntermediateTags {
+				r.intermediateTags = append(r.intermediateTags, intermediateTag{version: v, commitID: commit.ID.String()})
+			}
+		}
+	}
+
+	// like the new version, commits without a bump of their own are a patch
+	if r.stats.CommitsSincePreviousRun > 0 && r.previousRunBump == nil {
+		r.previousRunBump = patchBumper
+	}
+	if _, ok := r.previousRunBump.(major); ok && r.lockMajor {
+		r.previousRunBump = minorBumper
+	}
+
+	// the last boundary crossed is the final version, which is tagged at the head of the branch
+	if len(r.intermediateTags) > 0 {
+		r.intermediateTags = r.intermediateTags[:len(r.intermediateTags)-1]
+	}
+
+	// if there is no movement on the version from commits, bump patch
+	if r.strategy.Compare(r.newVersion, r.currentVersion) == 0 {
+		if r.strictMatch {
+			return fmt.Errorf("no version to bump found in commit message")
+		}
+		var b bumper
+		if r.bumpFromTagAnnotation {
+			if b, err = r.tagAnnotationBump(); err != nil {
+				return err
+			}
+		}
+		if b == nil && r.changeHeuristics {
+			if b, err = r.changeBump(start); err != nil {
+				return err
+			}
+		}
+		if b == nil {
+			b = patchBumper
+		}
+		if r.newVersion, err = r.applyBump(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notesRef returns the full ref of a git notes ref, which like `git notes --ref` may be given without
+// the refs/notes/ namespace
+func notesRef(ref string) string {
+	if strings.HasPrefix(ref, "refs/") {
+		return ref
+	}
+	return "refs/notes/" + ref
+}
+
+// readBumpNotes returns the notes of the BumpNotesRef on the commits by the ID of the commit they are
+// attached to. The notes are read with a single `git cat-file --batch`, however many notes the ref has.
+// A missing notes ref has no notes.
+func (r *GitRepo) readBumpNotes(commits []*git.Commit) (map[string]string, error) {
+	ref := notesRef(r.bumpNotesRef)
+	if _, err := git.NewCommand("show-ref", "--verify", "--quiet", ref).RunInDir(r.repo.Path()); err != nil {
+		return nil, nil
+	}
+	out, err := git.NewCommand("notes", "--ref", ref, "list").RunInDir(r.repo.Path())
+	if err != nil {
+		return nil, fmt.Errorf("error listing notes '%s': %s", ref, err)
+	}
+	inRange := make(map[string]bool, len(commits))
+	for _, c := range commits {
+		inRange[c.ID.String()] = true
+	}
+	var blobs, noted []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		blob, commit, ok := strings.Cut(line, " ")
+		if ok && inRange[commit] {
+			blobs = append(blobs, blob)
+			noted = append(noted, commit)
+		}
+	}
+	if len(blobs) == 0 {
+		return nil, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = git.NewCommand("cat-file", "--batch").RunInDirWithOptions(r.repo.Path(), git.RunInDirOptions{
+		Stdin:  strings.NewReader(strings.Join(blobs, "\n") + "\n"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading notes '%s': %s", ref, strings.TrimSpace(stderr.String()))
+	}
+	// each object is a `<sha> <type> <size>` line, the content and a newline
+	notes := make(map[string]string, len(blobs))
+	for _, commit := range noted {
+		header, err := stdout.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("error reading note of commit '%s': %s", commit, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("error reading note of commit '%s': %s", commit, strings.TrimSpace(header))
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size+1 > stdout.Len() {
+			return nil, fmt.Errorf("error reading note of commit '%s': %s", commit, strings.TrimSpace(header))
+		}
+		notes[commit] = string(stdout.Next(size))
+		stdout.Next(1)
+	}
+	return notes, nil
+}
+
+// tagAnnotationBump returns the bump of a `Next-Bump: <bump>` line in the message of the base tag, or
+// nil if the base tag is not annotated or has no such line
+func (r *GitRepo) tagAnnotationBump() (bumper, error) {
+	out, err := git.NewCommand("for-each-ref", "--format=%(objecttype)%00%(contents)", r.tagRef(r.currentTagName)).RunInDir(r.repo.Path())
+	if err != nil {
+		return nil, fmt.Errorf("error reading tag '%s': %s", r.currentTagName, err)
+	}
+	objectType, contents, _ := strings.Cut(string(out), "\x00")
+	if objectType != "tag" {
+		return nil, nil
+	}
+	m := nextBumpRex.FindStringSubmatch(contents)
+	if m == nil {
+		return nil, nil
+	}
+	b, ok := namedBumpers[m[1]]
+	if !ok {
+		return nil, fmt.Errorf("tag '%s' Next-Bump '%s' is not valid; must be (major|minor|patch)", r.currentTagName, m[1])
+	}
+	log.Printf("Using bump %s from the annotation of tag %s", b, r.currentTagName)
+	return b, nil
+}
+
+// changeBump infers the bump from the files changed between the start commit and the branch head,
+// only below the PathScope if set, see ChangeHeuristics
+func (r *GitRepo) changeBump(start string) (bumper, error) {
+	args := []string{"diff", "--name-only", start, r.branchID}
+	if r.pathScope != "" {
+		args = append(args, "--", r.pathScope)
+	}
+	out, err := git.NewCommand(args...).RunInDir(r.repo.Path())
+	if err != nil {
+		return nil, fmt.Errorf("error reading changed files: %s", err)
+	}
+
+	var b bumper = patchBumper
+	for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		switch {
+		case file == "":
+			continue
+		case matchesPath(r.breakingPaths, file):
+			log.Printf("Change to breaking path %s, bumping major", file)
+			return majorBumper, nil
+		case matchesPath(r.publicAPIPaths, file):
+			log.Printf("Change to public API path %s, bumping minor", file)
+			b = minorBumper
+		}
+	}
+	return b, nil
+}
+
+// matchesPath reports whether the file or one of its parent directories matches any of the globs
+func matchesPath(patterns []string, file string) bool {
+	for p := file; p != "."; p = path.Dir(p) {
+		if matchesAny(patterns, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// resetBaseTags returns the names of the tags at or after the newest reset commit of the branch, nil
+// without a reset commit. The reset commits not released yet by a version tag must all reset to the
+// same version, the newest one is not silently preferred.
+func (r *GitRepo) resetBaseTags() (map[string]bool, error) {
+	grep := []string{"rev-list", "-E", "--grep=^Autotag-Reset:"}
+	scope := func(args []string) []string {
+		if r.pathScope != "" {
+			return append(args, "--", r.pathScope)
+		}
+		return args
+	}
+	out, err := git.NewCommand(scope(append(grep, r.branchID))...).RunInDir(r.repo.Path())
+	if err != nil {
+		return nil, fmt.Errorf("error listing reset commits: %s", err)
+	}
+	resets := strings.Fields(string(out))
+	if len(resets) == 0 {
+		return nil, nil
+	}
+
+	// the version tags exclude the reset commits they already released
+	var revs strings.Builder
+	revs.WriteString(r.branchID + "\n")
+	for _, t := range r.tags {
+		fmt.Fprintf(&revs, "^%s\n", t.SHA)
+	}
+	var stdout, stderr bytes.Buffer
+	err = git.NewCommand(scope(append(grep, "--stdin"))...).RunInDirWithOptions(r.repo.Path(), git.RunInDirOptions{
+		Stdin:  strings.NewReader(revs.String()),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing reset commits: %s", strings.TrimSpace(stderr.String()))
+	}
+	var pending []string
+	var pendingVersion *version.Version
+	conflict := false
+	for _, id := range strings.Fields(stdout.String()) {
+		v, err := r.resetCommitVersion(id)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		pending = append(pending, fmt.Sprintf("%s (%s)", id, v))
+		if pendingVersion == nil {
+			pendingVersion = v
+		} else if !pendingVersion.Equal(v) {
+			conflict = true
+		}
+	}
+	if conflict {
+		return nil, fmt.Errorf("conflicting Autotag-Reset versions: %s", strings.Join(pending, ", "))
+	}
+
+	// the trailer may be empty, the newest commit with a version resets the history
+	for _, id := range resets {
+		v, err := r.resetCommitVersion(id)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		out, err := git.NewCommand("for-each-ref", "--format=%(refname)", "--contains", id, r.tagRefNamespace+"/").RunInDir(r.repo.Path())
+		if err != nil {
+			return nil, fmt.Errorf("error listing the tags after reset commit %s: %s", id, err)
+		}
+		names := make(map[string]bool)
+		for _, ref := range strings.Fields(string(out)) {
+			names[strings.TrimPrefix(ref, r.tagRefNamespace+"/")] = true
+		}
+		return names, nil
+	}
+	return nil, nil
+}
+
+// resetCommitVersion reads the commit and returns the version of its `Autotag-Reset` trailer
+func (r *GitRepo) resetCommitVersion(id string) (*version.Version, error) {
+	c, err := r.repo.CatFileCommit(id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit %s: %s", id, err)
+	}
+	return r.resetVersion(c)
+}
+
+// resetVersion returns the version of an `Autotag-Reset: <version>` trailer in the commit message, or
+// nil if there is none
+func (r *GitRepo) resetVersion(commit *git.Commit) (*version.Version, error) {
+	m := resetTrailerRex.FindStringSubmatch(commit.Message)
+	if m == nil {
+		return nil, nil
+	}
+	v, err := maybeVersionFromTag(m[1], r.strategy)
+	if err == nil && v == nil {
+		err = fmt.Errorf("not a version")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("commit %s has an invalid Autotag-Reset version '%s': %s", commit.ID, m[1], err)
+	}
+	return v, nil
+}
+
+// calcVersion looks over commits since the last tag, and will apply the version bump needed. It will patch if no other instruction is found
+// it populates the repo.newVersion with the new calculated version. A .autotag-bump file overrides the commits.
+func (r *GitRepo) calcVersion() error {
+	r.newVersion = r.currentVersion
+
+	// the head commit blocks the release, whatever the other commits in the range say
+	head, err := r.repo.CommitByRevision(r.branchID)
+	if err != nil {
+		return fmt.Errorf("error reading commit '%s': %s", r.branchID, err)
+	}
+	if noReleaseRex.MatchString(head.Message) {
+		log.Printf("Commit %s blocks the release", r.branchID)
+		return ErrReleaseBlocked
+	}
+
+	if r.minReleaseInterval > 0 && r.lastReleased > 0 {
+		last := time.Unix(r.lastReleased, 0)
+		if elapsed := timeNow().Sub(last); elapsed < r.minReleaseInterval {
+			return fmt.Errorf("%w: released at %s, the minimum interval is %s", ErrTooSoon, last.UTC().Format(time.RFC3339), r.minReleaseInterval)
+		}
+	}
+
+	// a bump decided out-of-band overrides the commit messages
+	b, ok, err := r.readBumpFile()
+	if err != nil {
+		return err
+	}
+	if ok && b == nil {
+		log.Printf("No version bump requested by %s", bumpFileName)
+		r.noBump = true
+		return nil
+	}
+	if ok {
+		log.Printf("Using bump from %s", bumpFileName)
+		if r.newVersion, err = r.applyBump(b); err != nil {
+			return err
+		}
+	} else if err = r.bumpFromCommits(); errors.Is(err, ErrNothingToTag) && r.skipWhenNothingToTag {
+		log.Printf("No version bump, %s is already tagged", r.branchID)
+		r.noBump = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if r.releaseTrain != "" {
+		if r.newVersion, err = r.trainVersion(r.newVersion); err != nil {
+			return err
+		}
+	}
+
+	// append pre-release-name and/or pre-release-timestamp to the version
+	if len(r.preReleaseName) > 0 || len(r.preReleaseTimestampLayout) > 0 {
+		if r.stabilizedPolicy == "error" {
+			if tag := r.stabilizedPreRelease(); tag != "" {
+				return fmt.Errorf("%w: '%s' was released after pre-release tag '%s'", ErrAlreadyStabilized, r.currentTagName, tag)
+			}
+		}
+
+		stable := r.newVersion
+		if r.newVersion, err = preReleaseVersion(r.newVersion, r.curPreReleaseVer, r.preReleaseName, r.preReleaseTimestampLayout, r.preReleaseNumber); err != nil {
+			return err
+		}
+
+		// too many pre-releases, promote to the stable version
+		if n, ok := preReleaseCounter(r.newVersion); ok && r.maxPreReleaseNumber > 0 && n > uint64(r.maxPreReleaseNumber) {
+			log.Printf("pre-release number %d exceeds the maximum of %d, promoting to %s", n, r.maxPreReleaseNumber, stable)
+			r.newVersion = stable
+		}
+	}
+
+	// append optional build metadata
+	if r.buildNumber {
+		if r.buildMetadata != "" {
+			return fmt.Errorf("cannot input custom method if enable build number")
+		}
+
+		metadata := r.latestTagVersion.Metadata()
+		if r.metadataIncludeBranch {
+			metadata, _, _ = strings.Cut(metadata, ".")
+		}
+		buildMetadata := ""
+		if metadata == "" {
+			buildMetadata = "1"
+		} else {
+			currentBuildNumber, err := strconv.ParseUint(metadata, 10, 64)
+			switch {
+			case err == nil:
+				buildMetadata = strconv.FormatUint(currentBuildNumber+1, 10)
+			case r.buildNumberResetOnError:
+				log.Printf("Resetting build number, metadata '%s' is not an unsigned integer", metadata)
+				buildMetadata = "1"
+			default:
+				return fmt.Errorf("build number must be a unsigned integer")
+			}
+		}
+		if r.metadataIncludeBranch {
+			branch, err := r.branchMetadata()
+			if err != nil {
+				return err
+			}
+			buildMetadata += "." + branch
+		}
+
+		if r.newVersion, err = version.NewVersion(fmt.Sprintf("%s+%s", r.newVersion.String(), buildMetadata)); err != nil {
+			return err
+		}
+	} else if r.buildMetadata != "" || len(r.metadataFromEnv) > 0 || r.stableChannel != "" || r.metadataIncludeBranch {
+		metadata, err := r.envMetadata()
+		if err != nil {
+			return err
+		}
+		if metadata != "" {
+			if r.newVersion, err = version.NewVersion(fmt.Sprintf("%s+%s", r.newVersion.String(), metadata)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// envMetadata returns the StableChannel marker of stable releases and BuildMetadata, joined with the
+// sanitized values of the MetadataFromEnv variables and the branch with MetadataIncludeBranch
+func (r *GitRepo) envMetadata() (string, error) {
+	var identifiers []string
+	// stable releases carry the marker so the next run recognizes them
+	if r.stableChannel != "" && r.newVersion.Prerelease() == "" {
+		identifiers = append(identifiers, r.stableChannel)
+	}
+	if r.buildMetadata != "" {
+		identifiers = append(identifiers, r.buildMetadata)
+	}
+	for _, name := range r.metadataFromEnv {
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" {
+			log.Printf("skipping unset metadata environment variable %s", name)
+			continue
+		}
+		value = envMetadataSanitizeRex.ReplaceAllString(value, "-")
+		if !validateSemVerBuildMetadata(value) {
+			return "", fmt.Errorf("environment variable %s value '%s' is not valid SemVer build metadata", name, value)
+		}
+		identifiers = append(identifiers, value)
+	}
+	if r.metadataIncludeBranch {
+		branch, err := r.branchMetadata()
+		if err != nil {
+			return "", err
+		}
+		identifiers = append(identifiers, branch)
+	}
+	return strings.Join(identifiers, "."), nil
+}
+
+// branchMetadata returns the branch name sanitized as a build metadata identifier
+func (r *GitRepo) branchMetadata() (string, error) {
+	branch := branchMetadataSanitizeRex.ReplaceAllString(r.branch, "-")
+	if !validateSemVerBuildMetadata(branch) {
+		return "", fmt.Errorf("branch '%s' metadata '%s' is not valid SemVer build metadata", r.branch, branch)
+	}
+	return branch, nil
+}
+
+// branchAllowed reports whether the branch may be tagged, see AllowedBranches
+func (r *GitRepo) branchAllowed() bool {
+	if len(r.allowedBranches) == 0 {
+		return true
+	}
+	for _, b := range r.allowedBranches {
+		if b == r.branch {
+			return true
+		}
+	}
+	return false
+}
+
+// AutoTagResult is the tag created by AutoTag.
+type AutoTagResult struct {
+	// Tag is the name of the created tag, including any prefix, eg: `v1.2.3`. It is empty when no tag
+	// was created because there was no version bump.
+	Tag string
+
+	Version *version.Version
+
+	// SHA is the ID of the tagged commit.
+	SHA string
+
+	// ReleaseTag is the name of the annotated release tag created with DualTag, eg: `release/v1.2.3`.
+	ReleaseTag string
+}
+
+// AutoTag applies the new version tag thats calculated, returning the tag it created. Once the tag
+// exists it is returned along with any error of the later steps, eg: publishing the release.
+func (r *GitRepo) AutoTag() (AutoTagResult, error) {
+	if r.noBump {
+		log.Printf("Not tagging, no version bump from %s", r.currentVersion)
+		if r.dryRun {
+			return AutoTagResult{}, nil
+		}
+		return AutoTagResult{}, r.finishRun()
+	}
+
+	if !r.branchAllowed() {
+		return AutoTagResult{}, fmt.Errorf("branch '%s' is not allowed to be tagged; allowed branches: %s", r.branch, strings.Join(r.allowedBranches, ", "))
+	}
+
+	tagName, err := r.tagName(r.newVersion)
+	if err != nil {
+		return AutoTagResult{}, err
+	}
+	names, err := r.createdTagNames(tagName)
+	if err != nil {
+		return AutoTagResult{}, err
+	}
+	// every name is checked before the first tag is written, so a bad name never leaves a partial release
+	for _, name := range names {
+		if err := r.checkTagName(name); err != nil {
+			return AutoTagResult{}, err
+		}
+	}
+
+	if r.confirm != nil && !r.dryRun {
+		ok, err := r.confirm(tagName)
+		if err != nil {
+			return AutoTagResult{}, err
+		}
+		if !ok {
+			return AutoTagResult{}, ErrNotConfirmed
+		}
+	}
+
+	for _, t := range r.intermediateTags {
+		name, err := r.tagName(t.version)
+		if err != nil {
+			return AutoTagResult{}, err
+		}
+		if err := r.createTag(name, t.commitID, ""); err != nil {
+			return AutoTagResult{}, err
+		}
+	}
+
+	if err := r.tagNewVersion(); err != nil {
+		return AutoTagResult{}, err
+	}
+	// the tag exists from here on, so it is reported alongside any later error
+	result := AutoTagResult{Tag: tagName, Version: r.newVersion, SHA: r.branchID}
+	if r.dualTag {
+		if result.ReleaseTag, err = r.createReleaseTag(tagName); err != nil {
+			return result, err
+		}
+	}
+	if r.dryRun {
+		return result, nil
+	}
+
+	if r.floatingAliases {
+		if err := r.updateFloatingAliases(); err != nil {
+			return result, err
+		}
+	}
+
+	if r.push {
+		if err := r.pushTags(names); err != nil {
+			return result, fmt.Errorf("error pushing '%s' to remote '%s', the tags were created locally: %s", strings.Join(names, "', '"), r.remote, err)
+		}
+		log.Printf("Pushed tags %s to %s", strings.Join(names, ", "), r.remote)
+	}
+
+	if r.manifestFile != "" {
+		if err := r.recordRelease(tagName); err != nil {
+			return result, err
+		}
+	}
+
+	if r.publisher != nil {
+		if err := r.publish(); err != nil {
+			return result, err
+		}
+	}
+	return result, r.finishRun()
+}
+
+// createdTagNames returns the name of every tag AutoTag creates or moves for the new version tagName: the
+// intermediate tags, the version tag, the DualTag release tag and the floating aliases
+func (r *GitRepo) createdTagNames(tagName string) ([]string, error) {
+	var names []string
+	for _, t := range r.intermediateTags {
+		name, err := r.tagName(t.version)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	names = append(names, tagName)
+	if r.dualTag {
+		names = append(names, r.releaseTagPrefix+tagName)
+	}
+	if r.floatingAliases {
+		names = append(names, r.floatingAliasNames()...)
+	}
+	return names, nil
+}
+
+// createReleaseTag creates the annotated release tag of DualTag at the branch head, next to the version
+// tag, and returns its name. It is created like the version tag, eg: signed with Sign and checked with
+// DryRunValidate in a dry run.
+func (r *GitRepo) createReleaseTag(tagName string) (string, error) {
+	// the name is checked by AutoTag with checkTagName
+	name := r.releaseTagPrefix + tagName
+	message, err := tagMessageText(r.tagMessage, tagMessageData{
+		Version:         r.newVersion.String(),
+		PreviousVersion: r.currentVersion.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.createTag(name, r.branchID, message); err != nil {
+		if r.dryRun {
+			return "", err
+		}
+		return "", fmt.Errorf("error creating release tag '%s', the version tag '%s' was created: %s", name, tagName, err)
+	}
+	return name, nil
+}
+
+// PushTag pushes the named tag to the Remote, eg: the tag returned by AutoTag.
+func (r *GitRepo) PushTag(tag string) error {
+	if err := r.pushTags([]string{tag}); err != nil {
+		return fmt.Errorf("error pushing tag '%s' to remote '%s': %s", tag, r.remote, err)
+	}
+	log.Printf("Pushed tag %s to %s", tag, r.remote)
+	return nil
+}
+
+// pushTags pushes the named tags to the Remote in a single push. The floating aliases of the new version
+// are forced, as they move from the previous release.
+func (r *GitRepo) pushTags(names []string) error {
+	aliases := make(map[string]bool)
+	if r.floatingAliases {
+		for _, alias := range r.floatingAliasNames() {
+			aliases[alias] = true
+		}
+	}
+	args := []string{"push", r.remote}
+	for _, name := range names {
+		ref := r.tagRef(name)
+		if aliases[name] {
+			args = append(args, "+"+ref+":"+ref)
+			continue
+		}
+		args = append(args, ref+":"+ref)
+	}
+	_, err := git.NewCommand(args...).RunInDir(r.repo.Path())
+	return err
+}
+
+// finishRun completes a successful AutoTag, removing the used .autotag-bump file and recording the
+// branch head in the StateFile
+func (r *GitRepo) finishRun() error {
+	if err := r.finishBumpFile(); err != nil {
+		return err
+	}
+	if r.stateFile != "" {
+		return r.saveState()
+	}
+	return nil
+}
+
+// finishBumpFile removes the used .autotag-bump file when RemoveBumpFile is set
+func (r *GitRepo) finishBumpFile() error {
+	if !r.removeBumpFile {
+		return nil
+	}
+	if err := os.Remove(r.bumpFilePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing %s: %s", bumpFileName, err)
+	}
+	return nil
+}
+
+func (r *GitRepo) tagNewVersion() error {
+	tagName, err := r.tagName(r.newVersion)
+	if err != nil {
+		return err
+	}
+
+	// TODO:(jnelson) These should be configurable? Mon Sep 14 12:02:52 2015
+	var trailers []string
+	if r.includePrevious {
+		previous, err := r.tagName(r.currentVersion)
+		if err != nil {
+			return err
+		}
+		trailers = append(trailers, "Previous-Version: "+previous)
+	}
+	if r.includeIssues {
+		issues, err := r.closedIssues()
+		if err != nil {
+			return err
+		}
+		if len(issues) > 0 {
+			trailers = append(trailers, "Closes: "+strings.Join(issues, ", "))
+		}
+	}
+	var message string
+	switch {
+	case r.annotated || r.sign:
+		message, err = tagMessageText(r.tagMessage, tagMessageData{
+			Version:         r.newVersion.String(),
+			PreviousVersion: r.currentVersion.String(),
+		})
+		if err != nil {
+			return err
+		}
+	case len(trailers) > 0:
+		message = tagName
+	}
+	if len(trailers) > 0 {
+		message = fmt.Sprintf("%s\n\n%s", message, strings.Join(trailers, "\n"))
+	}
+	if err := r.createTag(tagName, r.branchID, message); err != nil {
+		return err
+	}
+	if !r.dryRun {
+		r.createdRef = r.tagRef(tagName)
 	}
+	return nil
+}
 
-	buf := &bytes.Buffer{}
+// tagMessageData are the fields of the TagMessage template
+type tagMessageData struct {
+	Version         string
+	PreviousVersion string
+}
 
-	if _, err := buf.WriteString(name); err != nil {
-		return nil, err
+// tagMessageText executes the TagMessage template, an empty template is the version
+func tagMessageText(text string, data tagMessageData) (string, error) {
+	if text == "" {
+		return data.Version, nil
 	}
+	tmpl, err := template.New("tag message").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("tag message '%s' is not a valid template: %s", text, err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("tag message '%s' is not a valid template: %s", text, err)
+	}
+	return b.String(), nil
+}
 
-	if len(tsLayout) > 0 {
-		// XXX(theckman): if the buffer already has content written to it, add
-		// the `.` character as a delimiter. The `+` character was not used as
-		// the delimiter because some systems that support version tags do not
-		// allow it within the string (looking at you, Docker).
-		if buf.Len() > 0 {
-			if _, err := buf.WriteString("."); err != nil {
-				return nil, err
-			}
-		}
-
-		var (
-			timestamp   string
-			currentTime = timeNow().UTC()
-		)
+// checkTagName checks a tag AutoTag creates or moves may have the name: it must be a valid ref, match
+// the TagPattern and, with CheckRefCollision, not be the name of a branch
+func (r *GitRepo) checkTagName(tagName string) error {
+	// the prefix, pre-release and metadata are only checked together once assembled
+	if err := validateRefName(r.tagRef(tagName)); err != nil {
+		return fmt.Errorf("tag '%s' is not a valid git ref: %s", tagName, err)
+	}
+	if err := checkRefFormat(r.tagRef(tagName)); err != nil {
+		return fmt.Errorf("'%s' is not a valid git tag name", tagName)
+	}
 
-		if tsLayout == "epoch" {
-			timestamp = strconv.FormatInt(currentTime.Unix(), 10)
-		} else {
-			timestamp = currentTime.Format(tsLayout)
+	if r.checkRefCollision {
+		if _, err := git.NewCommand("show-ref", "--verify", "--quiet", "refs/heads/"+tagName).RunInDir(r.repo.Path()); err == nil {
+			return fmt.Errorf("tag '%s' has the same name as a branch", tagName)
 		}
+	}
 
-		if _, err := buf.WriteString(timestamp); err != nil {
-			return nil, err
+	if r.tagPattern != "" {
+		// the pattern is checked by validateConfig
+		if ok, _ := path.Match(r.tagPattern, tagName); !ok {
+			return fmt.Errorf("tag '%s' does not match tag pattern '%s'", tagName, r.tagPattern)
 		}
-	} else {
-		if len(name) > 0 && autoIncrease {
-			// Write the `.` character
-			if buf.Len() > 0 {
-				if _, err := buf.WriteString("."); err != nil {
-					return nil, err
-				}
-			}
-
-			prereleaseNumber := "1"
-			if curPrereleaseVer != nil {
-				prerelease := curPrereleaseVer.Prerelease()
-				prereleaseParts := strings.Split(prerelease, ".")
-				if len(prereleaseParts) == 2 {
-					currentPrereleaseNumber, err := strconv.ParseUint(prereleaseParts[1], 10, 64)
-					if err != nil {
-						return nil, fmt.Errorf("prerelease build number must be a unsigned integer")
-					}
-
-					prereleaseNumber = strconv.FormatUint(currentPrereleaseNumber+1, 10)
-				}
-			}
+	}
+	return nil
+}
 
-			if _, err := buf.WriteString(prereleaseNumber); err != nil {
-				return nil, err
+// createTag creates the named tag pointing at the commit, an annotated tag if message is not empty. The
+// name is checked by AutoTag with checkTagName.
+func (r *GitRepo) createTag(tagName, commitID, message string) error {
+	if r.dryRun {
+		if r.dryRunValidate {
+			if err := r.validateTagRef(tagName, commitID); err != nil {
+				return err
 			}
 		}
+		log.Printf("Dry run, not writing tag %s at %s", r.tagRef(tagName), commitID)
+		return nil
 	}
 
-	verStr := fmt.Sprintf("%s-%s", v.String(), buf.String())
-	return version.NewVersion(verStr)
-}
-
-// calcVersion looks over commits since the last tag, and will apply the version bump needed. It will patch if no other instruction is found
-// it populates the repo.newVersion with the new calculated version
-func (r *GitRepo) calcVersion() error {
-	r.newVersion = r.currentVersion
-	if err := r.retrieveBranchInfo(); err != nil {
-		return err
+	log.Println("Writing Tag", r.tagRef(tagName))
+	var err error
+	switch {
+	case r.sign:
+		// signed tags are annotated, an intermediate tag has no message of its own
+		if message == "" {
+			message = tagName
+		}
+		args := []string{"tag", "-s", "-m", message}
+		if r.signingKey != "" {
+			args = append(args, "-u", r.signingKey)
+		}
+		if _, err := git.NewCommand(append(args, tagName, commitID)...).RunInDir(r.repo.Path()); err != nil {
+			return fmt.Errorf("error signing tag '%s', check the signing key and gpg agent are available: %s", tagName, err)
+		}
+		return nil
+	case message != "":
+		err = r.repo.CreateTag(tagName, commitID, git.CreateTagOptions{Annotated: true, Message: message})
+	case r.tagRefNamespace == defaultTagRefNamespace:
+		err = r.repo.CreateTag(tagName, commitID)
+	default:
+		// the empty old value ensures an existing reference is never overwritten
+		_, err = git.NewCommand("update-ref", r.tagRef(tagName), commitID, "").RunInDir(r.repo.Path())
 	}
-
-	startCommit, err := r.repo.BranchCommit(r.branch)
 	if err != nil {
-		return err
+		return fmt.Errorf("error creating tag: %s", err.Error())
 	}
+	return nil
+}
 
-	revList := []string{fmt.Sprintf("%s..%s", r.currentTag.ID, startCommit.ID)}
-
-	l, err := r.repo.RevList(revList)
-	if len(l) == 0 && r.strictMatch {
-		return fmt.Errorf("no version to bump for the same commit")
+// validateTagRef checks the tag could be created at the commit without writing it. The ref update is
+// prepared, which locks the ref and checks it does not exist, then aborted.
+func (r *GitRepo) validateTagRef(tagName, commitID string) error {
+	ref := r.tagRef(tagName)
+	if _, err := git.NewCommand("show-ref", "--verify", "--quiet", ref).RunInDir(r.repo.Path()); err == nil {
+		return fmt.Errorf("tag '%s' already exists", tagName)
 	}
+
+	var stderr bytes.Buffer
+	err := git.NewCommand("update-ref", "--stdin").RunInDirWithOptions(r.repo.Path(), git.RunInDirOptions{
+		Stdin:  strings.NewReader(fmt.Sprintf("start\ncreate %s %s\nprepare\nabort\n", ref, commitID)),
+		Stdout: io.Discard,
+		Stderr: &stderr,
+	})
 	if err != nil {
-		log.Printf("Error loading history for tag '%s': %s ", r.currentVersion, err.Error())
+		return fmt.Errorf("tag '%s' could not be created: %s", tagName, strings.TrimSpace(stderr.String()))
 	}
+	return nil
+}
 
-	// r.branchID is the newest commit; r.currentTag.ID is oldest
-	log.Printf("Checking commits from %s to %s ", r.branchID, r.currentTag.ID)
+// updateFloatingAliases points the major and minor alias tags of the new version at the new commit,
+// eg: v1 and v1.2 for v1.2.3. Pre-releases do not move the aliases.
+func (r *GitRepo) updateFloatingAliases() error {
+	aliases := r.floatingAliasNames()
 
-	// Revlist returns in reverse Chronological We want chronological. Then check each commit for bump messages
-	for i := len(l) - 1; i >= 0; i-- {
-		commit := l[i] // getting the reverse order element
-		if commit == nil {
-			return fmt.Errorf("commit pointed to nil object. This should not happen")
-		}
+	// check every alias before moving any, so a rejected alias doesn't leave the others half updated. The
+	// names are checked by AutoTag with checkTagName.
+	for _, alias := range aliases {
+		ref := r.tagRef(alias)
 
-		v, nerr := r.parseCommit(commit)
-		if nerr != nil {
-			return nerr
+		// an annotated tag was created on purpose, never move it
+		if out, err := git.NewCommand("cat-file", "-t", ref).RunInDir(r.repo.Path()); err == nil && strings.TrimSpace(string(out)) == "tag" {
+			return fmt.Errorf("tag '%s' is an annotated tag, not a floating alias", alias)
 		}
+	}
 
-		if v != nil && v.GreaterThan(r.newVersion) {
-			r.newVersion = v
+	for _, alias := range aliases {
+		ref := r.tagRef(alias)
+		log.Println("Moving alias", ref)
+		if _, err := git.NewCommand("update-ref", ref, r.branchID).RunInDir(r.repo.Path()); err != nil {
+			return fmt.Errorf("error updating alias '%s': %s", alias, err)
 		}
 	}
+	return nil
+}
 
-	// if there is no movement on the version from commits, bump patch
-	if r.newVersion.Equal(r.currentVersion) {
-		if r.strictMatch {
-			return fmt.Errorf("no version to bump found in commit message")
+// floatingAliasNames returns the major and minor alias tags of the new version, none for a pre-release
+func (r *GitRepo) floatingAliasNames() []string {
+	if r.newVersion.Prerelease() != "" {
+		return nil
+	}
+	s := r.newVersion.Segments()
+	prefix := r.tagNamePrefix()
+	return []string{fmt.Sprintf("%s%d", prefix, s[0]), fmt.Sprintf("%s%d.%d", prefix, s[0], s[1])}
+}
+
+// checkRefFormat checks that a full reference name is legal using `git check-ref-format`.
+func checkRefFormat(ref string) error {
+	_, err := git.NewCommand("check-ref-format", ref).Run()
+	return err
+}
+
+// validateRefName checks a full reference name against the rules of `git check-ref-format`, returning
+// an error naming the rule that is broken. Components are also limited to maxRefComponentLength.
+func validateRefName(ref string) error {
+	switch {
+	case ref == "@":
+		return fmt.Errorf("must not be '@'")
+	case strings.HasPrefix(ref, "/") || strings.HasSuffix(ref, "/"):
+		return fmt.Errorf("must not begin or end with '/'")
+	case strings.HasSuffix(ref, "."):
+		return fmt.Errorf("must not end with '.'")
+	}
+	for _, s := range []string{"..", "//", "@{"} {
+		if strings.Contains(ref, s) {
+			return fmt.Errorf("must not contain '%s'", s)
 		}
-		if r.newVersion, err = patchBumper.bump(r.currentVersion); err != nil {
-			return err
+	}
+	for _, c := range ref {
+		if c < 0x20 || c == 0x7f {
+			return fmt.Errorf("must not contain control character %q", c)
+		}
+		if strings.ContainsRune(" ~^:?*[\\", c) {
+			return fmt.Errorf("must not contain '%c'", c)
 		}
 	}
+	for _, component := range strings.Split(ref, "/") {
+		switch {
+		case strings.HasPrefix(component, "."):
+			return fmt.Errorf("component '%s' must not begin with '.'", component)
+		case strings.HasSuffix(component, ".lock"):
+			return fmt.Errorf("component '%s' must not end with '.lock'", component)
+		case len(component) > maxRefComponentLength:
+			return fmt.Errorf("component is %d bytes, exceeds the maximum of %d", len(component), maxRefComponentLength)
+		}
+	}
+	return nil
+}
 
-	// append pre-release-name and/or pre-release-timestamp to the version
-	if len(r.preReleaseName) > 0 || len(r.preReleaseTimestampLayout) > 0 {
-		if r.newVersion, err = preReleaseVersion(r.newVersion, r.curPreReleaseVer, r.preReleaseName, r.preReleaseTimestampLayout, r.preReleaseNumber); err != nil {
-			return err
+// parseCommit looks at HEAD commit see if we want to increment major/minor/patch
+func (r *GitRepo) parseCommit(commit *git.Commit) (*version.Version, error) {
+	return r.applyCommitMatch(commit, r.matchCommit(commit))
+}
+
+// commitParse is the result of reading a single commit with the commit message scheme
+type commitParse struct {
+	b     bumper
+	match CommitMatch
+	err   error
+}
+
+// matchCommit reads the commit with the commit message scheme. It does not modify the repo, so
+// commits can be matched concurrently.
+func (r *GitRepo) matchCommit(commit *git.Commit) commitParse {
+	msg := commit.Message
+	log.Printf("Parsing %s: %s\n", commit.ID, msg)
+
+	if r.strictMatch && r.maxSubjectLength > 0 {
+		if n := utf8.RuneCountInString(commit.Summary()); n > r.maxSubjectLength {
+			return commitParse{err: fmt.Errorf("commit %s subject is %d characters, exceeds the maximum of %d", commit.ID, n, r.maxSubjectLength)}
 		}
 	}
 
-	// append optional build metadata
-	if r.buildNumber {
-		if r.buildMetadata != "" {
-			return fmt.Errorf("cannot input custom method if enable build number")
+	// a note changes the bump after the fact, over anything the message says, even an empty one
+	if m := bumpNoteRex.FindStringSubmatch(r.bumpNotes[commit.ID.String()]); m != nil {
+		nb, ok := namedBumpers[strings.ToLower(m[1])]
+		if !ok {
+			return commitParse{err: fmt.Errorf("commit %s bump note '%s' is not valid; must be (major|minor|patch)", commit.ID, m[1])}
 		}
+		match := CommitMatch{SHA: commit.ID.String(), Summary: commit.Summary(), Bump: fmt.Sprint(nb), Rule: fmt.Sprintf("note `%s`", strings.TrimSpace(m[0]))}
+		return commitParse{b: nb, match: match}
+	}
 
-		metadata := r.latestTagVersion.Metadata()
-		buildMetadata := ""
-		if metadata == "" {
-			buildMetadata = "1"
-		} else {
-			currentBuildNumber, err := strconv.ParseUint(metadata, 10, 64)
-			if err != nil {
-				return fmt.Errorf("build number must be a unsigned integer")
-			}
+	if r.emptyMessagePolicy == "patch" && strings.TrimSpace(msg) == "" {
+		match := CommitMatch{SHA: commit.ID.String(), Summary: commit.Summary(), Bump: fmt.Sprint(patchBumper), Rule: "empty message -> patch"}
+		return commitParse{b: patchBumper, match: match}
+	}
 
-			buildMetadata = strconv.FormatUint(currentBuildNumber+1, 10)
+	emoji, rest := leadingEmoji(msg)
+	if r.stripLeadingEmoji && emoji != "" {
+		msg = rest
+	}
+
+	// with several schemes the highest bump wins, the rules of a commit without a bump are joined
+	var b bumper
+	var rule string
+	var misses []string
+	var trailer bool
+	if r.bumpTrailerRex != nil {
+		if m := r.bumpTrailerRex.FindStringSubmatch(msg); m != nil {
+			tb, ok := namedBumpers[strings.ToLower(m[1])]
+			if !ok {
+				return commitParse{err: fmt.Errorf("commit %s bump trailer '%s' is not valid; must be (major|minor|patch)", commit.ID, m[1])}
+			}
+			b, rule, trailer = tb, fmt.Sprintf("trailer `%s`", strings.TrimSpace(m[0])), true
 		}
+	}
+	for _, scheme := range r.schemes {
+		// the trailer takes precedence over the schemes
+		if trailer {
+			break
+		}
+		var sb bumper
+		var srule string
+		switch scheme {
+		case "conventional":
+			sb, srule = matchConventionalCommit(msg, r.strictMatch)
+		case "autotag":
+			if r.rejectAmbiguous {
+				if directives := autotagDirectives(msg); len(directives) > 1 {
+					return commitParse{err: fmt.Errorf("commit %s has conflicting bump directives: %s", commit.ID, strings.Join(directives, ", "))}
+				}
+			}
+			sb, srule = matchAutotagCommit(msg)
+		}
+		if sb == nil {
+			misses = append(misses, srule)
+		} else if bumpRank(sb) > bumpRank(b) {
+			b, rule = sb, srule
+		}
+	}
+	if b == nil {
+		rule = strings.Join(misses, "; ")
+	}
 
-		if r.newVersion, err = version.NewVersion(fmt.Sprintf("%s+%s", r.newVersion.String(), buildMetadata)); err != nil {
-			return err
+	if name, ok := r.emojiBumps[emoji]; ok && b == nil && emoji != "" {
+		b, rule = namedBumpers[name], fmt.Sprintf("emoji `%s` -> %s", emoji, name)
+	}
+
+	if b == nil && r.submoduleBumps {
+		ok, err := r.submoduleUpdate(commit)
+		if err != nil {
+			return commitParse{err: err}
 		}
-	} else if r.buildMetadata != "" {
-		if r.newVersion, err = version.NewVersion(fmt.Sprintf("%s+%s", r.newVersion.String(), r.buildMetadata)); err != nil {
-			return err
+		if ok {
+			b, rule = patchBumper, "submodule update -> patch"
 		}
 	}
 
-	return nil
+	match := CommitMatch{SHA: commit.ID.String(), Summary: commit.Summary(), Bump: "none", Rule: rule}
+	if b != nil {
+		match.Bump = fmt.Sprint(b)
+	}
+	return commitParse{b: b, match: match}
 }
 
-// AutoTag applies the new version tag thats calculated
-func (r *GitRepo) AutoTag() error {
-	return r.tagNewVersion()
+// splitSchemes returns the schemes of a comma separated Scheme, the default is "autotag"
+func splitSchemes(scheme string) []string {
+	if scheme == "" {
+		return []string{"autotag"}
+	}
+	schemes := strings.Split(scheme, ",")
+	for i := range schemes {
+		schemes[i] = strings.TrimSpace(schemes[i])
+	}
+	return schemes
 }
 
-func (r *GitRepo) tagNewVersion() error {
-	// TODO:(jnelson) These should be configurable? Mon Sep 14 12:02:52 2015
-	tagName := fmt.Sprintf("v%s", r.newVersion.String())
-	if !r.prefix {
-		tagName = r.newVersion.String()
+// validScheme reports whether every scheme of a comma separated Scheme is known
+func validScheme(scheme string) bool {
+	for _, s := range splitSchemes(scheme) {
+		if s != "autotag" && s != "conventional" {
+			return false
+		}
+	}
+	return true
+}
+
+// leadingEmoji splits a commit message into its leading emoji, a unicode emoji sequence or a gitmoji
+// shortcode, and the rest of the message without the whitespace after the emoji. The emoji is empty
+// if the message does not start with one.
+func leadingEmoji(msg string) (string, string) {
+	msg = strings.TrimLeftFunc(msg, unicode.IsSpace)
+	if code := gitmojiShortcodeRex.FindString(msg); code != "" {
+		return code, strings.TrimLeftFunc(msg[len(code):], unicode.IsSpace)
 	}
 
-	log.Println("Writing Tag", tagName)
-	err := r.repo.CreateTag(tagName, r.branchID)
-	if err != nil {
-		return fmt.Errorf("error creating tag: %s", err.Error())
+	end := 0
+	for i, c := range msg {
+		switch {
+		case unicode.Is(unicode.So, c):
+		case end > 0 && (unicode.Is(unicode.Sk, c) || unicode.Is(unicode.Mn, c) || c == '\u200d' || c == '\ufe0f'):
+			// skin tone modifiers, variation selectors and joiners only continue an emoji
+		default:
+			return msg[:end], strings.TrimLeftFunc(msg[end:], unicode.IsSpace)
+		}
+		end = i + utf8.RuneLen(c)
 	}
-	return nil
+	return msg, ""
 }
 
-// parseCommit looks at HEAD commit see if we want to increment major/minor/patch
-func (r *GitRepo) parseCommit(commit *git.Commit) (*version.Version, error) {
-	var b bumper
-	msg := commit.Message
-	log.Printf("Parsing %s: %s\n", commit.ID, msg)
+// submoduleUpdate reports whether the commit only updates submodule pointers (gitlinks), compared to
+// its first parent
+func (r *GitRepo) submoduleUpdate(commit *git.Commit) (bool, error) {
+	out, err := git.NewCommand("diff-tree", "--no-commit-id", "--root", "-r", commit.ID.String()).RunInDir(r.repo.Path())
+	if err != nil {
+		return false, fmt.Errorf("error reading changes of commit '%s': %s", commit.ID, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if lines[0] == "" {
+		return false, nil
+	}
+	for _, line := range lines {
+		// :<old mode> <new mode> <old sha> <new sha> <status>\t<path>
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != ":"+gitlinkMode && fields[1] != gitlinkMode) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
 
-	switch r.scheme {
-	case "conventional":
-		b = parseConventionalCommit(msg, r.strictMatch)
-	case "", "autotag":
-		b = parseAutotagCommit(msg)
+// applyCommitMatch records a matched commit and returns the version its bump gives, commits must be
+// applied in order
+func (r *GitRepo) applyCommitMatch(commit *git.Commit, p commitParse) (*version.Version, error) {
+	if p.err != nil {
+		return nil, p.err
 	}
+	r.matches = append(r.matches, p.match)
 
-	if r.strictMatch && b == nil {
+	if r.strictMatch && p.b == nil {
 		return nil, fmt.Errorf("no match found for commit %s", commit.ID)
 	}
 
+	if _, ok := p.b.(major); ok && r.lockMajor {
+		log.Printf("warning: commit %s requested a major bump, clamped by lock major", commit.ID)
+		r.stats.ClampedCommits = append(r.stats.ClampedCommits, commit.ID.String())
+	}
+
 	// fallback to patch bump if no matches from the scheme parsers
-	if b != nil {
-		return b.bump(r.currentVersion)
+	if p.b != nil {
+		return r.applyBump(p.b)
 	}
 
 	return nil, nil
 }
 
+// matchCommits matches the commits with the commit message scheme, in parallel for large ranges. The
+// results are in the same order as the commits.
+func (r *GitRepo) matchCommits(commits []*git.Commit) []commitParse {
+	results := make([]commitParse, len(commits))
+	workers := runtime.GOMAXPROCS(0)
+	if len(commits) < parallelParseThreshold || workers < 2 {
+		for i, c := range commits {
+			results[i] = r.matchCommit(c)
+		}
+		return results
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = r.matchCommit(commits[i])
+			}
+		}()
+	}
+	for i := range commits {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// applyBump applies the bump to the current version. When the current version is a pre-release
+// (see AllowPreReleaseBase) a patch bump finalizes it instead, eg: 1.0.0-rc.2 -> 1.0.0. With
+// LockMajor a major bump is applied as a minor bump.
+func (r *GitRepo) applyBump(b bumper) (*version.Version, error) {
+	if _, ok := b.(major); ok && r.lockMajor {
+		log.Println("major version is locked, bumping minor")
+		b = minorBumper
+	}
+	if bumpRank(b) > bumpRank(r.bump) {
+		r.bump = b
+	}
+	if _, ok := b.(patch); ok && r.currentVersion.Prerelease() != "" {
+		return r.currentVersion.Core(), nil
+	}
+	return b.bumpWith(r.strategy, r.currentVersion)
+}
+
+// trainVersion moves the bumped version onto the release train of the current date. Within the
+// current train the patch of the base version is incremented, a new train starts at patch 0.
+func (r *GitRepo) trainVersion(v *version.Version) (*version.Version, error) {
+	train := releaseTrainNumber(r.releaseTrain, timeNow())
+	base := r.currentVersion.Segments64()
+	major := v.Segments64()[0]
+
+	switch {
+	case major > base[0]:
+		return version.NewVersion(fmt.Sprintf("%d.%d.0", major, train))
+	case base[1] > int64(train):
+		return nil, fmt.Errorf("release train '%d' is behind the current version '%s'", train, r.currentVersion)
+	case base[1] == int64(train) && r.currentVersion.Prerelease() != "":
+		return r.currentVersion.Core(), nil
+	case base[1] == int64(train):
+		return version.NewVersion(fmt.Sprintf("%d.%d.%d", major, train, base[2]+1))
+	default:
+		log.Printf("Starting release train %d", train)
+		return version.NewVersion(fmt.Sprintf("%d.%d.0", major, train))
+	}
+}
+
+// releaseTrainNumber maps the UTC date to a release train number, see ReleaseTrain
+func releaseTrainNumber(train string, t time.Time) uint64 {
+	t = t.UTC()
+	if train == "month" {
+		return uint64(t.Year()*100 + int(t.Month()))
+	}
+	year, week := t.ISOWeek()
+	return uint64(year*100 + week)
+}
+
+// autotagDirectives returns the distinct bump directives of the autotag scheme found in the commit message
+func autotagDirectives(msg string) []string {
+	var directives []string
+	for _, d := range []struct {
+		name string
+		rex  *regexp.Regexp
+	}{{"major", majorRex}, {"minor", minorRex}, {"patch", patchRex}} {
+		if d.rex.MatchString(msg) {
+			directives = append(directives, d.name)
+		}
+	}
+	return directives
+}
+
 // parseAutotagCommit implements the autotag (default) commit scheme.
 // A git commit message header containing:
 //   - [major] or #major: major version bump
@@ -601,22 +3474,23 @@ func (r *GitRepo) parseCommit(commit *git.Commit) (*version.Version, error) {
 //
 // If no action is present nil is returned and the caller must decide what action to take.
 func parseAutotagCommit(msg string) bumper {
-	if majorRex.MatchString(msg) {
-		log.Println("major bump")
-		return majorBumper
-	}
-
-	if minorRex.MatchString(msg) {
-		log.Println("minor bump")
-		return minorBumper
-	}
+	b, _ := matchAutotagCommit(msg)
+	return b
+}
 
-	if patchRex.MatchString(msg) {
-		log.Println("patch bump")
-		return patchBumper
+// matchAutotagCommit implements parseAutotagCommit, also describing the rule that matched
+func matchAutotagCommit(msg string) (bumper, string) {
+	for _, d := range []struct {
+		name string
+		rex  *regexp.Regexp
+		b    bumper
+	}{{"majorRex", majorRex, majorBumper}, {"minorRex", minorRex, minorBumper}, {"patchRex", patchRex, patchBumper}} {
+		if m := d.rex.FindString(msg); m != "" {
+			log.Printf("%s bump", d.b)
+			return d.b, fmt.Sprintf("matched %s via `%s`", d.name, m)
+		}
 	}
-
-	return nil
+	return nil, "no bump directive"
 }
 
 // parseConventionalCommit implements the Conventional Commit scheme. Given a commit message
@@ -625,42 +3499,104 @@ func parseAutotagCommit(msg string) bumper {
 // it will return nil and the caller will decide what action to take.
 // https://www.conventionalcommits.org/en/v1.0.0/#summary
 func parseConventionalCommit(msg string, strictMatch bool) bumper {
+	b, _ := matchConventionalCommit(msg, strictMatch)
+	return b
+}
+
+// matchConventionalCommit implements parseConventionalCommit, also describing the rule that matched
+func matchConventionalCommit(msg string, strictMatch bool) (bumper, string) {
 	matches := findNamedMatches(conventionalCommitRex, msg)
 
 	// If we're in strict match and no matches are found, return nil
 	bumperType, authorized := conventionalCommitAuthorizedTypes[matches["type"]]
 	if strictMatch && !authorized {
-		return nil
+		return nil, fmt.Sprintf("conventional type `%s` is not recognized", matches["type"])
 	}
 
 	// If the commit contains a footer with 'BREAKING CHANGE:' it is always a major bump
 	if strings.Contains(msg, "\nBREAKING CHANGE:") {
-		return majorBumper
+		return majorBumper, "footer `BREAKING CHANGE:` -> major"
 	}
 
 	// If the type/scope in the header includes a trailing '!' this is a breaking change
 	if breaking, ok := matches["breaking"]; ok && breaking == "!" {
-		return majorBumper
+		return majorBumper, fmt.Sprintf("conventional type `%s!` -> major", matches["type"])
 	}
 
 	// If the type in the header match a type try to find it in the authorized list
 	// If it's not in the list it returns nil
-	return bumperType
+	if !authorized {
+		return nil, fmt.Sprintf("conventional type `%s` is not recognized", matches["type"])
+	}
+	return bumperType, fmt.Sprintf("conventional type `%s` -> %s", matches["type"], bumperType)
+}
+
+// SchemeConflicts reports the commits since the current tag where the autotag and conventional
+// schemes both find a bump directive but disagree on it, eg: `feat: [major] thing` is a major bump
+// for autotag and a minor bump for conventional commits. It is analysis only and useful to clean up
+// ambiguous messages when migrating between schemes.
+func (r *GitRepo) SchemeConflicts() ([]string, error) {
+	l, err := r.releaseCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	// oldest commit first
+	for i := len(l) - 1; i >= 0; i-- {
+		commit := l[i]
+		a := parseAutotagCommit(commit.Message)
+		c := parseConventionalCommit(commit.Message, false)
+		if a == nil || c == nil || a == c {
+			continue
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s: autotag %s, conventional %s: %s", commit.ID, a, c, commit.Summary()))
+	}
+	return conflicts, nil
+}
+
+// ReleaseContributors returns the number of commits by each author name since the current tag, eg:
+// for a "Thanks to" section in the release notes.
+func (r *GitRepo) ReleaseContributors() (map[string]int, error) {
+	l, err := r.releaseCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	contributors := make(map[string]int)
+	for _, commit := range l {
+		contributors[commit.Author.Name]++
+	}
+	return contributors, nil
+}
+
+// releaseCommits returns the commits between the current tag and the branch head, newest first
+func (r *GitRepo) releaseCommits() ([]*git.Commit, error) {
+	l, err := r.revList(fmt.Sprintf("%s..%s", r.currentTag.ID, r.branchID))
+	if err != nil {
+		return nil, fmt.Errorf("error loading history for tag '%s': %s", r.currentVersion, err)
+	}
+	return l, nil
+}
+
+// revList returns the commits of the refspecs newest first, only those touching the PathScope if set
+func (r *GitRepo) revList(refspecs ...string) ([]*git.Commit, error) {
+	return r.repo.RevList(refspecs, git.RevListOptions{Path: r.pathScope})
 }
 
 // MajorBump will bump the version one major rev 1.0.0 -> 2.0.0
 func (r *GitRepo) MajorBump() (*version.Version, error) {
-	return majorBumper.bump(r.currentVersion)
+	return r.strategy.BumpMajor(r.currentVersion)
 }
 
 // MinorBump will bump the version one minor rev 1.1.0 -> 1.2.0
 func (r *GitRepo) MinorBump() (*version.Version, error) {
-	return minorBumper.bump(r.currentVersion)
+	return r.strategy.BumpMinor(r.currentVersion)
 }
 
 // PatchBump will bump the version one patch rev 1.1.1 -> 1.1.2
 func (r *GitRepo) PatchBump() (*version.Version, error) {
-	return patchBumper.bump(r.currentVersion)
+	return r.strategy.BumpPatch(r.currentVersion)
 }
 
 // findNamedMatches is a helper function for use with regexes containing named capture groups.
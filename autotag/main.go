@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/autotag-dev/autotag"
 	"github.com/jessevdk/go-flags"
@@ -14,16 +18,88 @@ import (
 type Options struct {
 	JustVersion         bool   `short:"n" description:"Just output the next version, don't autotag"`
 	Verbose             bool   `short:"v" description:"Enable verbose logging"`
-	Branch              string `short:"b" long:"branch" description:"Git branch to scan (defaults to main, then master)" default:""`
+	Branch              string `short:"b" long:"branch" description:"Git branch to scan, may be a remote-tracking branch, eg: origin/main (defaults to main, then master)" default:""`
 	RepoPath            string `short:"r" long:"repo" description:"Path to the repo" default:"./" `
 	PreReleaseName      string `short:"p" long:"pre-release-name" description:"create a pre-release tag"`
 	PreReleaseTimestamp string `short:"T" long:"pre-release-timestamp" description:"create a pre-release tag and append a timestamp (can be: datetime|epoch)"`
 	PreReleaseNumber    bool   `long:"pre-release-number" description:"create a pre-release tag and append a pre-release number"`
+	StabilizedPolicy    string `long:"pre-release-stabilized-policy" description:"Pre-release version when the base was released as stable after pre-releases of it (can be: bump-base|error)" default:"bump-base"`
+	MaxPreReleaseNumber int    `long:"max-pre-release-number" description:"Promote to the stable version when the pre-release number would exceed this, 0 disables the limit"`
+	StrictPreRelease    bool   `long:"strict-pre-release-ordering" description:"Return an error if the pre-release name and number would not sort in increasing order"`
 	BuildMetadata       string `short:"m" long:"build-metadata" description:"optional SemVer build metadata to append to the version with '+' character"`
-	Scheme              string `short:"s" long:"scheme" description:"The commit message scheme to use (can be: autotag|conventional)" default:"autotag"`
+	Scheme              string `short:"s" long:"scheme" description:"The commit message scheme to use (can be: autotag|conventional, or a comma separated list of them), defaults to $AUTOTAG_SCHEME, then autotag"`
 	NoVersionPrefix     bool   `short:"e" long:"empty-version-prefix" description:"Do not prepend v to version tag"`
+	TagPrefix           string `long:"tag-prefix" description:"Prefix to prepend to the version tag instead of v, may contain a {date} placeholder (YYYYMMDD)"`
+	PathScope           string `long:"path-scope" description:"Version the module at this path of a monorepo, from the commits touching it, with tags prefixed by the path, eg: frontend/v1.2.3"`
+	TagRefNamespace     string `long:"tag-ref-namespace" description:"Reference namespace to read and write version tags (default: refs/tags)"`
 	StrictMatch         bool   `long:"strict-match" description:"Enforce strict mode on the scheme parsers, returns error if no match is found"`
+	EmptyMessagePolicy  string `long:"empty-message-policy" description:"Treatment of commits with an empty message (can be: patch|skip|error), defaults to no bump"`
+	BumpTrailerKey      string `long:"bump-trailer-key" description:"Commit message trailer declaring the bump, taking precedence over the scheme, eg: Semver-Bump for 'Semver-Bump: minor'"`
+	BumpNotesRef        string `long:"bump-notes-ref" description:"Git notes ref whose 'bump=<bump>' notes override the bump of the commit they are attached to, eg: bumps"`
+	RejectAmbiguous     bool   `long:"reject-ambiguous-directives" description:"Return an error if a commit mixes different bump directives, eg: '[major] [minor]'"`
+	LockMajor           bool   `long:"lock-major" description:"Never bump the major version, major bumps are applied as minor bumps"`
+	ReleaseTrain        string `long:"release-train" description:"Set the minor version to the release train of the current date, patch increments within a train (can be: isoweek|month)"`
+	SkipNothingToTag    bool   `long:"skip-when-nothing-to-tag" description:"Exit without tagging, instead of an error, if the branch head is already tagged"`
+	SkipMergeCommits    bool   `long:"skip-merge-commits" description:"Ignore merge commits when looking for version bumps"`
+	RejectFuture        bool   `long:"reject-future-commits" description:"Return an error if a commit since the latest tag is dated in the future"`
+	StripLeadingEmoji   bool   `long:"strip-leading-emoji" description:"Remove a leading emoji, eg: ✨ or :sparkles:, from commit messages before parsing them"`
+	SubmoduleBumps      bool   `long:"submodule-bumps" description:"Treat commits that only update submodule pointers as patch bumps, also with --strict-match"`
+	ChangeHeuristics    bool   `long:"change-heuristics" description:"Infer the bump from the changed files when no commit message has a bump directive, see --public-api-path and --breaking-path"`
+	IgnoreSubmodules    bool   `long:"ignore-submodule-commits" description:"Ignore commits that only update submodule pointers when looking for version bumps"`
+	MaxSubjectLength    int    `long:"max-subject-length" description:"Maximum commit subject length enforced with --strict-match, 0 disables the check"`
+	DryRun              bool   `long:"dry-run" description:"Check and log the tag that would be created, without creating it or anything else"`
+	Push                bool   `long:"push" description:"Push the new tag, and every other tag created with it, to the remote after creating it"`
+	Remote              string `long:"remote" description:"Remote the tag is pushed to with --push" default:"origin"`
+	DualTag             bool   `long:"dual-tag" description:"Also create an annotated release tag on the same commit as the version tag, see --release-tag-prefix"`
+	ReleaseTagPrefix    string `long:"release-tag-prefix" description:"Prefix prepended to the version tag to name the release tag of --dual-tag (default: release/)"`
+	DryRunValidate      bool   `long:"dry-run-validate" description:"With --dry-run, also check the tag does not exist and its ref can be written"`
+	Confirm             bool   `long:"confirm" description:"Ask for confirmation on the terminal before creating the tag"`
+	FloatingAliases     bool   `long:"floating-aliases" description:"Also move major and minor alias tags, eg: v1 and v1.2, to every new stable release"`
+	Describe            bool   `long:"describe" description:"Output a git describe style string for the branch head, eg: v1.2.3-5-gabc1234, instead of the version"`
+	CompareURL          string `long:"compare-url" description:"Output a link to the diff of the release on the repository at this URL, eg: https://github.com/org/repo, instead of the version"`
+	Summary             string `long:"summary" description:"Output a summary of the release with the version, previous version, bump, commit and changelog, instead of the version (can be: json|yaml|text)"`
+	ForgeType           string `long:"forge-type" description:"URL shape of --compare-url (can be: github|gitlab)" default:"github"`
+	MarketingVersion    int    `long:"marketing-version" description:"Output the version truncated to this number of segments (1-3) without pre-release or metadata, eg: 1.2, instead of the version"`
+	DockerTag           bool   `long:"docker-tag" description:"Output the version as a Docker image tag, lowercase with '+' replaced"`
+	DockerTagSeparator  string `long:"docker-tag-separator" description:"Character replacing '+' in the Docker image tag (can be: _|.|-)" default:"_"`
+	GoModuleCompat      bool   `long:"go-module-compat" description:"Output the version as the Go toolchain expects it, with +incompatible for major versions 2 and above"`
+	IncludePrevious     bool   `long:"tag-message-include-previous" description:"Create an annotated tag whose message includes the previous version, eg: 'Previous-Version: v1.2.2'"`
+	Annotated           bool   `long:"annotated" description:"Create an annotated tag, with the version as message unless --tag-message is set"`
+	TagMessage          string `long:"tag-message" description:"Message of the annotated or --dual-tag release tag, may use {{.Version}} and {{.PreviousVersion}}, eg: 'Release {{.Version}}'"`
+	Sign                bool   `long:"sign" description:"Create a GPG signed annotated tag, as 'git tag -s'"`
+	SigningKey          string `long:"signing-key" description:"Key to sign the tag with, as 'git tag -u' (defaults to git config user.signingkey)"`
+	IncludeIssues       bool   `long:"tag-message-include-issues" description:"Create an annotated tag whose message lists the issues closed in the release, eg: 'Closes: #123, #456'"`
+	CheckRefCollision   bool   `long:"check-ref-collision" description:"Return an error if a branch has the same name as the new tag"`
+	TagPattern          string `long:"tag-pattern" description:"Glob the new tag must match before it is created, eg: 'v[0-9]*'"`
+	FourSegment         bool   `long:"four-segment" description:"Support Major.Minor.Patch.Revision version tags, eg: v1.2.3.4, instead of skipping them"`
+	LegacySeparators    bool   `long:"legacy-separators" description:"Accept existing version tags using '_' or '-' between numbers, eg: v1_2_3"`
+	IntermediateTags    bool   `long:"intermediate-tags" description:"Also create a tag at every commit where the version crosses a bump boundary"`
+	AllowPreReleaseBase bool   `long:"allow-pre-release-base" description:"Use the latest pre-release tag as the base version when no stable version tag exists"`
+	RemoveBumpFile      bool   `long:"remove-bump-file" description:"Delete the .autotag-bump file after tagging"`
+	DateSource          string `long:"date-source" description:"Commit date used for ordering and filtering (can be: author|committer)" default:"author"`
+	ManifestFile        string `long:"manifest-file" description:"Append a record of the release to this JSON file after tagging"`
+	MetadataBranch      bool   `long:"metadata-include-branch" description:"Append the branch name as build metadata, eg: +main, with characters not allowed replaced by '-'"`
+	StableChannel       string `long:"stable-channel" description:"Build metadata identifier marking stable tags, only marked tags are used as the base, eg: stable for v1.2.3+stable"`
+	StateFile           string `long:"state-file" description:"Record the tagged commit in this file and report the commits since the previous run"`
 	BuildNumber         bool   `long:"build-number" description:"Enforce append build number in metadata (after '+' character), returns error if metadata is not a unsigned integer or empty"`
+	BuildNumberReset    bool   `long:"build-number-reset-on-error" description:"Restart the build number at 1 if the latest metadata is not an unsigned integer, instead of returning an error"`
+	BaseTagLabel        string `long:"base-tag-label" description:"Use the highest annotated tag with this line in its message as the base version, eg: 'Autotag-Base: true'"`
+	BaseVersion         string `long:"base-version" description:"Calculate the next version from this version instead of the latest stable tag, requires --base-commit"`
+	BaseCommit          string `long:"base-commit" description:"Commit the --base-version was released at, only the commits after it are checked for bumps"`
+	BumpFromTag         bool   `long:"bump-from-tag-annotation" description:"Apply the bump of a 'Next-Bump: major|minor|patch' line in the base tag message when no commit has a bump directive"`
+	RequireSignedBase   bool   `long:"require-signed-base-tag" description:"Verify the signature of the latest stable tag, returns error if it is unsigned or cannot be verified"`
+	RequireSignedAbove  bool   `long:"require-signed-superseded" description:"With --require-signed-base-tag, fail instead of warning when a tag sorting above the base tag is unsigned"`
+
+	AllowedBranches []string `long:"allowed-branch" description:"Branch that may be tagged, may be repeated (defaults to any branch)"`
+	BranchFallback  []string `long:"branch-fallback" description:"Branch to use when --branch is empty or not found, may be repeated to try several in order (defaults to main, then master)"`
+	VersionArtifact []string `long:"version-artifact" description:"Write the version into a file as format:path, may be repeated, eg: go:version.go, json:package.json or toml:pyproject.toml"`
+	ChannelOrder    []string `long:"channel" description:"Pre-release channel in promotion order, lowest first, may be repeated, eg: --channel=dev --channel=rc"`
+	MetadataFromEnv []string `long:"metadata-from-env" description:"Environment variable whose value is appended as build metadata, may be repeated, eg: GITHUB_RUN_NUMBER"`
+	PublicAPIPaths  []string `long:"public-api-path" description:"Glob of public API files or directories, changes are a minor bump with --change-heuristics, may be repeated"`
+	BreakingPaths   []string `long:"breaking-path" description:"Glob of files or directories whose changes are a major bump with --change-heuristics, may be repeated"`
+
+	MinReleaseInterval time.Duration     `long:"min-release-interval" description:"Minimum time since the latest release, returns an error if it has not elapsed, eg: 1h"`
+	EmojiBumps         map[string]string `long:"emoji-bump" key-value-delimiter:"=" description:"Bump of commits with this leading emoji and no bump directive as emoji=bump, may be repeated, eg: 💥=major or :bug:=patch"`
 }
 
 var opts Options
@@ -42,17 +118,98 @@ func main() {
 		log.SetOutput(os.Stderr)
 	}
 
+	var confirm func(string) (bool, error)
+	if opts.Confirm {
+		confirm = promptConfirm
+	}
+
+	var artifacts []autotag.ArtifactSpec
+	for _, a := range opts.VersionArtifact {
+		format, path, ok := strings.Cut(a, ":")
+		if !ok {
+			log.SetOutput(os.Stderr)
+			log.Println("Error initializing: version artifact '" + a + "' must be format:path")
+			os.Exit(1)
+		}
+		artifacts = append(artifacts, autotag.ArtifactSpec{Format: format, Path: path})
+	}
+
 	r, err := autotag.NewRepo(autotag.GitRepoConfig{
-		RepoPath:                  opts.RepoPath,
-		Branch:                    opts.Branch,
-		PreReleaseName:            opts.PreReleaseName,
-		PreReleaseTimestampLayout: opts.PreReleaseTimestamp,
-		PreReleaseNumber:          opts.PreReleaseNumber,
-		BuildMetadata:             opts.BuildMetadata,
-		Scheme:                    opts.Scheme,
-		Prefix:                    !opts.NoVersionPrefix,
-		StrictMatch:               opts.StrictMatch,
-		BuildNumber:               opts.BuildNumber,
+		RepoPath:                   opts.RepoPath,
+		Branch:                     opts.Branch,
+		AllowedBranches:            opts.AllowedBranches,
+		BranchFallback:             opts.BranchFallback,
+		PreReleaseName:             opts.PreReleaseName,
+		PreReleaseTimestampLayout:  opts.PreReleaseTimestamp,
+		PreReleaseNumber:           opts.PreReleaseNumber,
+		ChannelOrder:               opts.ChannelOrder,
+		MaxPreReleaseNumber:        opts.MaxPreReleaseNumber,
+		StrictPreReleaseOrdering:   opts.StrictPreRelease,
+		PreReleaseStabilizedPolicy: opts.StabilizedPolicy,
+		BuildMetadata:              opts.BuildMetadata,
+		MetadataFromEnv:            opts.MetadataFromEnv,
+		MetadataIncludeBranch:      opts.MetadataBranch,
+		StableChannel:              opts.StableChannel,
+		Scheme:                     opts.Scheme,
+		FourSegment:                opts.FourSegment,
+		Prefix:                     !opts.NoVersionPrefix,
+		TagPrefix:                  opts.TagPrefix,
+		PathScope:                  opts.PathScope,
+		TagRefNamespace:            opts.TagRefNamespace,
+		StrictMatch:                opts.StrictMatch,
+		SkipWhenNothingToTag:       opts.SkipNothingToTag,
+		LockMajor:                  opts.LockMajor,
+		RejectAmbiguousDirectives:  opts.RejectAmbiguous,
+		BumpTrailerKey:             opts.BumpTrailerKey,
+		BumpNotesRef:               opts.BumpNotesRef,
+		EmptyMessagePolicy:         opts.EmptyMessagePolicy,
+		StripLeadingEmoji:          opts.StripLeadingEmoji,
+		EmojiBumps:                 opts.EmojiBumps,
+		ReleaseTrain:               opts.ReleaseTrain,
+		MinReleaseInterval:         opts.MinReleaseInterval,
+		MaxSubjectLength:           opts.MaxSubjectLength,
+		SkipMergeCommits:           opts.SkipMergeCommits,
+		RejectFutureCommits:        opts.RejectFuture,
+		SubmoduleBumps:             opts.SubmoduleBumps,
+		IgnoreSubmoduleCommits:     opts.IgnoreSubmodules,
+		ChangeHeuristics:           opts.ChangeHeuristics,
+		PublicAPIPaths:             opts.PublicAPIPaths,
+		BreakingPaths:              opts.BreakingPaths,
+		BuildNumber:                opts.BuildNumber,
+		BuildNumberResetOnError:    opts.BuildNumberReset,
+		AllowPreReleaseBase:        opts.AllowPreReleaseBase,
+		CreateIntermediateTags:     opts.IntermediateTags,
+		LegacySeparators:           opts.LegacySeparators,
+		TagPattern:                 opts.TagPattern,
+		CheckRefCollision:          opts.CheckRefCollision,
+		TagMessageIncludePrevious:  opts.IncludePrevious,
+		TagMessageIncludeIssues:    opts.IncludeIssues,
+		Annotated:                  opts.Annotated,
+		TagMessage:                 opts.TagMessage,
+		Sign:                       opts.Sign,
+		SigningKey:                 opts.SigningKey,
+		GoModuleCompat:             opts.GoModuleCompat,
+		DockerTagSeparator:         opts.DockerTagSeparator,
+		FloatingAliases:            opts.FloatingAliases,
+		DryRun:                     opts.DryRun,
+		DryRunValidate:             opts.DryRunValidate,
+		Push:                       opts.Push,
+		Remote:                     opts.Remote,
+		DualTag:                    opts.DualTag,
+		ReleaseTagPrefix:           opts.ReleaseTagPrefix,
+		Confirm:                    confirm,
+		RemoveBumpFile:             opts.RemoveBumpFile,
+		DateSource:                 opts.DateSource,
+		ForgeType:                  opts.ForgeType,
+		ManifestFile:               opts.ManifestFile,
+		StateFile:                  opts.StateFile,
+		VersionArtifacts:           artifacts,
+		RequireSignedBaseTag:       opts.RequireSignedBase,
+		BaseTagLabel:               opts.BaseTagLabel,
+		BaseVersion:                opts.BaseVersion,
+		BaseCommit:                 opts.BaseCommit,
+		BumpFromTagAnnotation:      opts.BumpFromTag,
+		RequireSignedSuperseded:    opts.RequireSignedAbove,
 	})
 	if err != nil {
 		log.SetOutput(os.Stderr)
@@ -60,9 +217,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the new version is always bumped from the base tag, the state only adds the commits since the previous run
+	if opts.StateFile != "" {
+		fmt.Fprintf(os.Stderr, "%d commits since the previous run (%s)\n", r.Stats().CommitsSincePreviousRun, r.PreviousRunBump())
+	}
+
 	// Tag unless asked otherwise
 	if !opts.JustVersion {
-		err = r.AutoTag()
+		_, err = r.AutoTag()
+		if errors.Is(err, autotag.ErrNotConfirmed) {
+			os.Exit(0)
+		}
 		if err != nil {
 			log.SetOutput(os.Stderr)
 			log.Println("Error auto updating version: " + err.Error())
@@ -70,8 +235,53 @@ func main() {
 		}
 	}
 
-	fmt.Println(r.LatestVersion())
+	// -n only prints the version, nothing is written
+	if !opts.JustVersion {
+		if err := r.WriteVersionArtifacts(); err != nil {
+			log.SetOutput(os.Stderr)
+			log.Println("Error writing version artifacts: " + err.Error())
+			os.Exit(1)
+		}
+	}
+
+	switch {
+	case opts.Describe:
+		describe, err := r.Describe()
+		if err != nil {
+			log.SetOutput(os.Stderr)
+			log.Println("Error describing branch: " + err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(describe)
+	case opts.Summary != "":
+		if err := r.WriteSummary(os.Stdout, opts.Summary); err != nil {
+			log.SetOutput(os.Stderr)
+			log.Println("Error writing summary: " + err.Error())
+			os.Exit(1)
+		}
+	case opts.CompareURL != "":
+		fmt.Println(r.CompareURL(opts.CompareURL))
+	case opts.GoModuleCompat:
+		fmt.Println(r.GoModuleVersion())
+	case opts.MarketingVersion > 0:
+		fmt.Println(r.MarketingVersion(opts.MarketingVersion))
+	case opts.DockerTag:
+		fmt.Println(r.DockerTag())
+	default:
+		fmt.Println(r.LatestVersion())
+	}
 
 	// TODO:(jnelson) Add -major -minor -patch flags for force bumps Fri Sep 11 10:04:20 2015
 	os.Exit(0)
 }
+
+// promptConfirm asks on stderr whether to create the tag, reading the answer from stdin
+func promptConfirm(tag string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "Create tag %s? [y/N] ", tag)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
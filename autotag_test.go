@@ -1,13 +1,24 @@
 package autotag
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	assert "github.com/alecthomas/assert/v2"
 	"github.com/gogs/git-module"
+	"github.com/hashicorp/go-version"
 )
 
 func init() {
@@ -46,6 +57,9 @@ type testRepoSetup struct {
 	// (optional) prepend literal 'v' to version tags (default: true)
 	disablePrefix bool
 
+	// (optional) prefix to prepend to version tags instead of 'v', may contain a {date} placeholder
+	tagPrefix string
+
 	// (optional) commit message to use for the next, untagged commit. Settings this allows for testing the
 	// commit message parsing logic. eg: "#major this is a major commit"
 	nextCommit string
@@ -58,6 +72,12 @@ type testRepoSetup struct {
 
 	// (optional) will enforce append build number in metadata and return error if cannot bump (default: false)
 	buildNumber bool
+
+	// (optional) restart the build number at 1 instead of returning an error if cannot bump (default: false)
+	buildNumberResetOnError bool
+
+	// (optional) maximum commit subject length enforced under strict match (default: 0, disabled)
+	maxSubjectLength int
 }
 
 // newTestRepo creates a new git repo in a temporary directory and returns an autotag.GitRepo struct for
@@ -110,8 +130,11 @@ func newTestRepo(t *testing.T, setup testRepoSetup) (GitRepo, error) {
 		BuildMetadata:             setup.buildMetadata,
 		Scheme:                    setup.scheme,
 		Prefix:                    !setup.disablePrefix,
+		TagPrefix:                 setup.tagPrefix,
 		StrictMatch:               setup.strictMatch,
 		BuildNumber:               setup.buildNumber,
+		BuildNumberResetOnError:   setup.buildNumberResetOnError,
+		MaxSubjectLength:          setup.maxSubjectLength,
 	})
 	if err != nil {
 		return GitRepo{}, err
@@ -175,6 +198,408 @@ func TestValidateConfig(t *testing.T) {
 			},
 			shouldErr: true,
 		},
+		{
+			name: "invalid tag ref namespace - outside refs/",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				TagRefNamespace: "tags",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid tag ref namespace - trailing slash",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				TagRefNamespace: "refs/release-tags/",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid tag ref namespace - illegal characters",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				TagRefNamespace: "refs/release tags",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid max subject length",
+			cfg: GitRepoConfig{
+				Branch:           "master",
+				MaxSubjectLength: -1,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "require signed superseded without signed base",
+			cfg: GitRepoConfig{
+				Branch:                  "master",
+				RequireSignedSuperseded: true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid docker tag separator",
+			cfg: GitRepoConfig{
+				Branch:             "master",
+				DockerTagSeparator: "+",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "tag message include previous with custom namespace",
+			cfg: GitRepoConfig{
+				Branch:                    "master",
+				TagRefNamespace:           "refs/release-tags",
+				TagMessageIncludePrevious: true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "tag message include issues with custom namespace",
+			cfg: GitRepoConfig{
+				Branch:                  "master",
+				TagRefNamespace:         "refs/release-tags",
+				TagMessageIncludeIssues: true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "annotated with custom namespace",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				TagRefNamespace: "refs/release-tags",
+				Annotated:       true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "tag message without annotated",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				TagMessage: "Release {{.Version}}",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "dry run validate without dry run",
+			cfg: GitRepoConfig{
+				Branch:         "master",
+				DryRunValidate: true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "absolute path scope",
+			cfg: GitRepoConfig{
+				Branch:    "master",
+				PathScope: "/frontend",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "path scope outside the repository",
+			cfg: GitRepoConfig{
+				Branch:    "master",
+				PathScope: "../frontend",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "intermediate tags with pre-release name",
+			cfg: GitRepoConfig{
+				Branch:                 "master",
+				CreateIntermediateTags: true,
+				PreReleaseName:         "rc",
+				PreReleaseNumber:       true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "intermediate tags with build number",
+			cfg: GitRepoConfig{
+				Branch:                 "master",
+				CreateIntermediateTags: true,
+				BuildNumber:            true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "intermediate tags with release train",
+			cfg: GitRepoConfig{
+				Branch:                 "master",
+				CreateIntermediateTags: true,
+				ReleaseTrain:           "isoweek",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid fallback branch",
+			cfg: GitRepoConfig{
+				BranchFallback: []string{"main", "bad..name"},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid bump notes ref",
+			cfg: GitRepoConfig{
+				Branch:       "master",
+				BumpNotesRef: "bumps..old",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "dual tag with custom namespace",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				TagRefNamespace: "refs/release-tags",
+				DualTag:         true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "release tag prefix without dual tag",
+			cfg: GitRepoConfig{
+				Branch:           "master",
+				ReleaseTagPrefix: "releases/",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "sign with custom namespace",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				TagRefNamespace: "refs/release-tags",
+				Sign:            true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "signing key without sign",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				SigningKey: "release@example.com",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "tag message with dual tag",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				DualTag:    true,
+				TagMessage: "Release {{.Version}}",
+			},
+			shouldErr: false,
+		},
+		{
+			name: "tag message with sign",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				Sign:       true,
+				TagMessage: "Release {{.Version}}",
+			},
+			shouldErr: false,
+		},
+		{
+			name: "tag message with invalid template",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				Annotated:  true,
+				TagMessage: "Release {{.Version}",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "tag message with unknown field",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				Annotated:  true,
+				TagMessage: "Release {{.Name}}",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "metadata from env with build number",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				BuildNumber:     true,
+				MetadataFromEnv: []string{"BUILD_ID"},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid public API path",
+			cfg: GitRepoConfig{
+				Branch:         "master",
+				PublicAPIPaths: []string{"api/["},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid breaking path",
+			cfg: GitRepoConfig{
+				Branch:        "master",
+				BreakingPaths: []string{"schema/["},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid emoji bump",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				EmojiBumps: map[string]string{"💥": "breaking"},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "submodule bumps with ignored submodule commits",
+			cfg: GitRepoConfig{
+				Branch:                 "master",
+				SubmoduleBumps:         true,
+				IgnoreSubmoduleCommits: true,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid forge type",
+			cfg: GitRepoConfig{
+				Branch:    "master",
+				ForgeType: "bitbucket",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid stable channel",
+			cfg: GitRepoConfig{
+				Branch:        "master",
+				StableChannel: "stable.1",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "stable channel with build number",
+			cfg: GitRepoConfig{
+				Branch:        "master",
+				BuildNumber:   true,
+				StableChannel: "stable",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid channel",
+			cfg: GitRepoConfig{
+				Branch:       "master",
+				ChannelOrder: []string{"dev", "rc.1"},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "negative min release interval",
+			cfg: GitRepoConfig{
+				Branch:             "master",
+				MinReleaseInterval: -time.Hour,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "four segment with custom version strategy",
+			cfg: GitRepoConfig{
+				Branch:          "master",
+				FourSegment:     true,
+				VersionStrategy: FourSegmentStrategy{},
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid scheme in list",
+			cfg: GitRepoConfig{
+				Branch: "master",
+				Scheme: "autotag,gitmoji",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "base version without base commit",
+			cfg: GitRepoConfig{
+				Branch:      "master",
+				BaseVersion: "1.2.3",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "base commit without base version",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				BaseCommit: "HEAD~1",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "base version with base tag label",
+			cfg: GitRepoConfig{
+				Branch:       "master",
+				BaseVersion:  "1.2.3",
+				BaseCommit:   "HEAD~1",
+				BaseTagLabel: "Autotag-Base: true",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid empty message policy",
+			cfg: GitRepoConfig{
+				Branch:             "master",
+				EmptyMessagePolicy: "ignore",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid bump trailer key",
+			cfg: GitRepoConfig{
+				Branch:         "master",
+				BumpTrailerKey: "Semver Bump",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid pre-release stabilized policy",
+			cfg: GitRepoConfig{
+				Branch:                     "master",
+				PreReleaseStabilizedPolicy: "ignore",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid release train",
+			cfg: GitRepoConfig{
+				Branch:       "master",
+				ReleaseTrain: "sprint",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid max pre-release number",
+			cfg: GitRepoConfig{
+				Branch:              "master",
+				MaxPreReleaseNumber: -1,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid date source",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				DateSource: "tagger",
+			},
+			shouldErr: true,
+		},
+		{
+			name: "invalid tag pattern",
+			cfg: GitRepoConfig{
+				Branch:     "master",
+				TagPattern: "v[0-9",
+			},
+			shouldErr: true,
+		},
 		{
 			name: "valid config with all options used",
 			cfg: GitRepoConfig{
@@ -289,12 +714,81 @@ func TestNewRepoMainAndMaster(t *testing.T) {
 	}
 }
 
-func TestNewRepoStrictMatch(t *testing.T) {
+func TestBranchFallback(t *testing.T) {
 	tests := []struct {
-		name  string
-		setup testRepoSetup
+		name        string
+		branch      string
+		fallback    []string
+		expected    string
+		expectedErr string
 	}{
-		// tests for autotag (default) scheme
+		{
+			name:     "empty branch uses the first fallback found",
+			fallback: []string{"main", "master", "develop", "release"},
+			expected: "develop",
+		},
+		{
+			name:     "missing branch uses the fallback",
+			branch:   "trunk",
+			fallback: []string{"main", "develop"},
+			expected: "develop",
+		},
+		{
+			name:     "existing branch",
+			branch:   "release",
+			fallback: []string{"develop"},
+			expected: "release",
+		},
+		{
+			name:        "empty branch without fallback",
+			expectedErr: "no main or master branch found",
+		},
+		{
+			name:        "missing branch without fallback",
+			branch:      "trunk",
+			expectedErr: "error getting head commit",
+		},
+		{
+			name:        "no fallback found",
+			branch:      "trunk",
+			fallback:    []string{"main", "master"},
+			expectedErr: "branch 'trunk' not found, and no main or master fallback branch found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "develop")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			runGit(t, repo, "branch", "release")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:       repo.Path(),
+				Branch:         tc.branch,
+				BranchFallback: tc.fallback,
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.branch)
+			assert.Equal(t, tc.expected, r.EffectiveConfig().Branch)
+		})
+	}
+}
+
+func TestNewRepoStrictMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup testRepoSetup
+	}{
+		// tests for autotag (default) scheme
 		{
 			name: "autotag scheme, bad type commit fails with strict match",
 			setup: testRepoSetup{
@@ -359,6 +853,57 @@ func TestNewRepoStrictMatch(t *testing.T) {
 	}
 }
 
+func TestMaxSubjectLength(t *testing.T) {
+	// "[minor] add a feature" is 21 characters long
+	tests := []struct {
+		name        string
+		strictMatch bool
+		max         int
+		shouldErr   bool
+	}{
+		{
+			name:        "subject below the cap",
+			strictMatch: true,
+			max:         22,
+		},
+		{
+			name:        "subject at the cap",
+			strictMatch: true,
+			max:         21,
+		},
+		{
+			name:        "subject above the cap",
+			strictMatch: true,
+			max:         20,
+			shouldErr:   true,
+		},
+		{
+			name:        "subject above the cap is ignored without strict match",
+			strictMatch: false,
+			max:         20,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag:       "v1.0.0",
+				nextCommit:       "[minor] add a feature\n\na body line that is much longer than the subject cap",
+				strictMatch:      tc.strictMatch,
+				maxSubjectLength: tc.max,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			assert.Equal(t, "1.1.0", r.LatestVersion())
+		})
+	}
+}
+
 func TestMajor(t *testing.T) {
 	r, err := newTestRepo(t, testRepoSetup{
 		branch:     "master",
@@ -516,6 +1061,144 @@ func TestPrereleaseNumberWithNewVersion(t *testing.T) {
 	}
 }
 
+func TestPreReleaseStabilizedPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		tags        []string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "default bumps the base",
+			tags:     []string{"v1.0.2-dev.1", "v1.0.2"},
+			expected: "1.0.3-dev.1",
+		},
+		{
+			name:     "bump-base",
+			policy:   "bump-base",
+			tags:     []string{"v1.0.2-dev.1", "v1.0.2"},
+			expected: "1.0.3-dev.1",
+		},
+		{
+			name:        "error",
+			policy:      "error",
+			tags:        []string{"v1.0.2-dev.1", "v1.0.2-dev.2", "v1.0.2"},
+			expectedErr: "pre-release base was already stabilized: 'v1.0.2' was released after pre-release tag 'v1.0.2-dev.2'",
+		},
+		{
+			name:     "error without pre-releases of the base",
+			policy:   "error",
+			tags:     []string{"v1.0.2"},
+			expected: "1.0.3-dev.1",
+		},
+		{
+			name:     "error with pre-releases in another channel",
+			policy:   "error",
+			tags:     []string{"v1.0.2-rc.1", "v1.0.2"},
+			expected: "1.0.3-dev.1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.1", repo)
+			updateReadme(t, repo, "dev work")
+			for _, tag := range tc.tags {
+				makeTag(repo, tag)
+			}
+			updateReadme(t, repo, "more dev work")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:                   repo.Path(),
+				Branch:                     "main",
+				Prefix:                     true,
+				PreReleaseName:             "dev",
+				PreReleaseNumber:           true,
+				PreReleaseStabilizedPolicy: tc.policy,
+			})
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.IsError(t, err, ErrAlreadyStabilized)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestMetadataIncludeBranch(t *testing.T) {
+	tests := []struct {
+		name          string
+		branch        string
+		tag           string
+		buildMetadata string
+		buildNumber   bool
+		expected      string
+	}{
+		{
+			name:     "simple branch",
+			branch:   "main",
+			tag:      "v1.0.0",
+			expected: "1.0.1+main",
+		},
+		{
+			name:     "branch needing sanitization",
+			branch:   "release/2.x",
+			tag:      "v1.0.0",
+			expected: "1.0.1+release-2-x",
+		},
+		{
+			name:          "after build metadata",
+			branch:        "main",
+			tag:           "v1.0.0",
+			buildMetadata: "abc",
+			expected:      "1.0.1+abc.main",
+		},
+		{
+			name:        "with build number",
+			branch:      "main",
+			tag:         "v1.0.0+4.feature-x",
+			buildNumber: true,
+			expected:    "1.0.1+5.main",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, tc.branch)
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, tc.tag, repo)
+			updateReadme(t, repo, "a fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:              repo.Path(),
+				Branch:                tc.branch,
+				BuildMetadata:         tc.buildMetadata,
+				BuildNumber:           tc.buildNumber,
+				MetadataIncludeBranch: true,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestBranchMetadataInvalid(t *testing.T) {
+	r := &GitRepo{branch: ""}
+	_, err := r.branchMetadata()
+	assert.EqualError(t, err, "branch '' metadata '' is not valid SemVer build metadata")
+}
+
 func TestBuildNumberFirstTime(t *testing.T) {
 	r, err := newTestRepo(t, testRepoSetup{
 		buildNumber: true,
@@ -568,6 +1251,100 @@ func TestBuildNumberWithPrelease(t *testing.T) {
 	}
 }
 
+func TestBuildNumberResetOnError(t *testing.T) {
+	tests := []struct {
+		name      string
+		reset     bool
+		expected  string
+		shouldErr bool
+	}{
+		{
+			name:      "error on alphanumeric metadata",
+			shouldErr: true,
+		},
+		{
+			name:     "reset on alphanumeric metadata",
+			reset:    true,
+			expected: "1.0.2+1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag:              "v1.0.1+build.abc",
+				buildNumber:             true,
+				buildNumberResetOnError: tc.reset,
+			})
+			if tc.shouldErr {
+				assert.EqualError(t, err, "build number must be a unsigned integer")
+				return
+			}
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestAllowPreReleaseBase(t *testing.T) {
+	tests := []struct {
+		name             string
+		commit           string
+		preReleaseName   string
+		preReleaseNumber bool
+		expected         string
+	}{
+		{
+			name:     "patch bump finalizes the pre-release base",
+			commit:   "a basic change",
+			expected: "1.0.0",
+		},
+		{
+			name:     "minor bump",
+			commit:   "[minor] new feature",
+			expected: "1.1.0",
+		},
+		{
+			name:             "pre-release number continues from the base",
+			commit:           "a basic change",
+			preReleaseName:   "rc",
+			preReleaseNumber: true,
+			expected:         "1.0.0-rc.3",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0-rc.1", repo)
+			updateReadme(t, repo, "second release candidate")
+			makeTag(repo, "v1.0.0-rc.2")
+			updateReadme(t, repo, tc.commit)
+
+			cfg := GitRepoConfig{
+				RepoPath:         repo.Path(),
+				Branch:           "main",
+				PreReleaseName:   tc.preReleaseName,
+				PreReleaseNumber: tc.preReleaseNumber,
+			}
+			_, err = NewRepo(cfg)
+			assert.Error(t, err)
+
+			cfg.AllowPreReleaseBase = true
+			r, err := NewRepo(cfg)
+			checkFatal(t, err)
+			assert.Equal(t, "1.0.0-rc.2", r.currentVersion.String())
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
 func TestMissingInitialTag(t *testing.T) {
 	tr := createTestRepo(t, "")
 	repo, err := git.Open(tr)
@@ -583,287 +1360,4730 @@ func TestMissingInitialTag(t *testing.T) {
 	assert.Error(t, err)
 }
 
-func TestAutoTag(t *testing.T) {
+func TestSkipMergeCommits(t *testing.T) {
+	tests := []struct {
+		name     string
+		skip     bool
+		expected string
+	}{
+		{
+			name:     "merge commit directive is honored by default",
+			skip:     false,
+			expected: "2.0.0",
+		},
+		{
+			name:     "merge commit directive is ignored",
+			skip:     true,
+			expected: "1.0.1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+
+			runGit(t, repo, "checkout", "-b", "feature")
+			updateReadme(t, repo, "#patch feature work")
+			runGit(t, repo, "checkout", "main")
+			err = os.WriteFile(filepath.Join(tr, "OTHER"), []byte("other\n"), 0o644)
+			checkFatal(t, err)
+			makeCommit(repo, "unrelated change on main")
+			runGit(t, repo, "merge", "--no-ff", "feature", "-m", "[major] Merge pull request #1 from feature")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:         repo.Path(),
+				Branch:           "main",
+				SkipMergeCommits: tc.skip,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestSubmoduleCommits(t *testing.T) {
 	tests := []struct {
 		name        string
-		setup       testRepoSetup
+		message     string
+		strictMatch bool
+		bumps       bool
+		ignore      bool
+		expected    string
 		shouldErr   bool
-		expectedTag string
 	}{
-		// tests for autotag (default) scheme
 		{
-			name: "autotag scheme, [major] bump",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "[major] this is a big release\n\nfoo bar baz\n",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v2.0.0",
+			name:        "submodule update fails strict match by default",
+			message:     "update submodule",
+			strictMatch: true,
+			shouldErr:   true,
 		},
 		{
-			name: "autotag scheme, [minor] bump",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "[minor] this is a smaller release\n\nfoo bar baz\n",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v1.1.0",
+			name:        "submodule update is a patch bump",
+			message:     "update submodule",
+			strictMatch: true,
+			bumps:       true,
+			expected:    "1.0.1",
 		},
 		{
-			name: "autotag scheme, patch bump",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "this is just a basic change\n\nfoo bar baz\n",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v1.0.1",
+			name:     "directive wins over submodule bump",
+			message:  "[minor] update submodule",
+			bumps:    true,
+			expected: "1.1.0",
 		},
 		{
-			name: "autotag scheme, #major bump",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "#major this is a big release\n\nfoo bar baz\n",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v2.0.0",
+			name:     "submodule update directive is ignored",
+			message:  "[minor] update submodule",
+			ignore:   true,
+			expected: "1.0.1",
 		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+
+			// point a gitlink at the seed commit, no submodule checkout is needed
+			head := runGit(t, repo, "rev-parse", "HEAD")
+			runGit(t, repo, "update-index", "--add", "--cacheinfo", "160000,"+head+",sub")
+			runGit(t, repo, "commit", "-m", tc.message)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:               repo.Path(),
+				Branch:                 "main",
+				StrictMatch:            tc.strictMatch,
+				SubmoduleBumps:         tc.bumps,
+				IgnoreSubmoduleCommits: tc.ignore,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestChangeHeuristics(t *testing.T) {
+	tests := []struct {
+		name       string
+		heuristics bool
+		files      []string
+		message    string
+		expected   string
+	}{
 		{
-			name: "autotag scheme, #minor bump",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "#minor this is a smaller release\n\nfoo bar baz\n",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v1.1.0",
+			name:     "disabled",
+			files:    []string{"api/client.go"},
+			expected: "1.0.1",
 		},
 		{
-			name: "pre-release-name with patch bump",
-			setup: testRepoSetup{
-				scheme:         "autotag",
-				nextCommit:     "#patch bump",
-				initialTag:     "v1.0.0",
-				preReleaseName: "dev",
-			},
-			expectedTag: "v1.0.1-dev",
+			name:       "other change is a patch bump",
+			heuristics: true,
+			files:      []string{"docs/usage.md"},
+			expected:   "1.0.1",
 		},
 		{
-			name: "epoch pre-release-timestamp",
-			setup: testRepoSetup{
-				scheme:                    "autotag",
-				nextCommit:                "#patch bump",
-				initialTag:                "v1.0.0",
-				preReleaseTimestampLayout: "epoch",
-			},
-			expectedTag: fmt.Sprintf("v1.0.1-%d", timeNow().UTC().Unix()),
+			name:       "public API change is a minor bump",
+			heuristics: true,
+			files:      []string{"docs/usage.md", "api/v1/client.go"},
+			expected:   "1.1.0",
 		},
 		{
-			name: "datetime pre-release-timestamp",
-			setup: testRepoSetup{
-				scheme:                    "autotag",
-				nextCommit:                "#patch bump",
-				initialTag:                "v1.0.0",
-				preReleaseTimestampLayout: "datetime",
-			},
-			expectedTag: fmt.Sprintf("v1.0.1-%s", timeNow().Format(datetimeTsLayout)),
+			name:       "breaking path change is a major bump",
+			heuristics: true,
+			files:      []string{"api/v1/client.go", "schema/migrations/002.sql"},
+			expected:   "2.0.0",
 		},
 		{
-			name: "epoch pre-release-timestamp and pre-release-name",
+			name:       "glob matches a file",
+			heuristics: true,
+			files:      []string{"pkg/store/api.go"},
+			expected:   "1.1.0",
+		},
+		{
+			name:       "commit message directive wins",
+			heuristics: true,
+			files:      []string{"schema/migrations/002.sql"},
+			message:    "[minor] add a column",
+			expected:   "1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			for _, f := range tc.files {
+				checkFatal(t, os.MkdirAll(filepath.Join(tr, filepath.Dir(f)), 0o755))
+				checkFatal(t, os.WriteFile(filepath.Join(tr, f), []byte("change\n"), 0o644))
+			}
+			message := tc.message
+			if message == "" {
+				message = "some changes"
+			}
+			makeCommit(repo, message)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:         repo.Path(),
+				Branch:           "main",
+				ChangeHeuristics: tc.heuristics,
+				PublicAPIPaths:   []string{"api", "pkg/*/api.go"},
+				BreakingPaths:    []string{"schema/migrations"},
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestLeadingEmoji(t *testing.T) {
+	tests := []struct {
+		msg   string
+		emoji string
+		rest  string
+	}{
+		{msg: "✨ feat: add thing", emoji: "✨", rest: "feat: add thing"},
+		{msg: "  💥breaking", emoji: "💥", rest: "breaking"},
+		{msg: "👍🏽 thumbs", emoji: "👍🏽", rest: "thumbs"},
+		{msg: "❤️ love", emoji: "❤️", rest: "love"},
+		{msg: ":sparkles: feat: add thing", emoji: ":sparkles:", rest: "feat: add thing"},
+		{msg: "feat: ✨ add thing", emoji: "", rest: "feat: ✨ add thing"},
+		{msg: "fix(scope): thing", emoji: "", rest: "fix(scope): thing"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.msg, func(t *testing.T) {
+			emoji, rest := leadingEmoji(tc.msg)
+			assert.Equal(t, tc.emoji, emoji)
+			assert.Equal(t, tc.rest, rest)
+		})
+	}
+}
+
+func TestGitmojiCommits(t *testing.T) {
+	emojiBumps := map[string]string{"💥": "major", ":bug:": "patch"}
+	tests := []struct {
+		name       string
+		message    string
+		strip      bool
+		emojiBumps map[string]string
+		expected   string
+		shouldErr  bool
+	}{
+		{
+			name:      "emoji prefixed conventional commit is not matched",
+			message:   "✨ feat: add thing",
+			shouldErr: true,
+		},
+		{
+			name:     "emoji prefixed conventional commit",
+			message:  "✨ feat: add thing",
+			strip:    true,
+			expected: "1.1.0",
+		},
+		{
+			name:     "emoji prefixed breaking conventional commit",
+			message:  "✨ feat!: replace thing",
+			strip:    true,
+			expected: "2.0.0",
+		},
+		{
+			name:       "gitmoji only commit",
+			message:    "💥 remove the old API",
+			strip:      true,
+			emojiBumps: emojiBumps,
+			expected:   "2.0.0",
+		},
+		{
+			name:       "gitmoji shortcode commit",
+			message:    ":bug: fix a crash",
+			emojiBumps: emojiBumps,
+			expected:   "1.0.1",
+		},
+		{
+			name:       "conventional type wins over the emoji",
+			message:    "💥 feat: add thing",
+			strip:      true,
+			emojiBumps: emojiBumps,
+			expected:   "1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, tc.message)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:          repo.Path(),
+				Branch:            "main",
+				Scheme:            "conventional",
+				StrictMatch:       true,
+				StripLeadingEmoji: tc.strip,
+				EmojiBumps:        tc.emojiBumps,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestBaseVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		tag         string
+		baseVersion string
+		baseCommit  string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:        "without tags",
+			baseVersion: "1.4.0",
+			expected:    "1.5.0",
+		},
+		{
+			name:        "tags are ignored",
+			tag:         "v9.0.0",
+			baseVersion: "1.4.0",
+			expected:    "1.5.0",
+		},
+		{
+			name:        "invalid base version",
+			baseVersion: "one",
+			expectedErr: "base version 'one' is not a valid version",
+		},
+		{
+			name:        "unknown base commit",
+			baseVersion: "1.4.0",
+			baseCommit:  "deadbeef",
+			expectedErr: "error reading base commit 'deadbeef'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			makeCommit(repo, "initial commit")
+			if tc.tag != "" {
+				makeTag(repo, tc.tag)
+			}
+			// the major bump before the base commit is already part of the base version
+			updateReadme(t, repo, "[major] rewrite")
+			baseCommit := tc.baseCommit
+			if baseCommit == "" {
+				baseCommit = runGit(t, repo, "rev-parse", "HEAD")
+			}
+			updateReadme(t, repo, "[minor] add thing")
+			updateReadme(t, repo, "fix thing")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:    repo.Path(),
+				Branch:      "main",
+				BaseVersion: tc.baseVersion,
+				BaseCommit:  baseCommit,
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+			assert.Equal(t, "Bumping 1.4.0 → 1.5.0 (minor)", r.BumpMessage())
+			assert.Equal(t, "https://github.com/org/repo/compare/"+baseCommit+"...1.5.0", r.CompareURL("https://github.com/org/repo"))
+		})
+	}
+}
+
+func TestPreReleaseMetadataWarning(t *testing.T) {
+	tests := []struct {
+		name           string
+		tag            string
+		preReleaseName string
+		expected       []string
+	}{
+		{
+			name:           "name matches metadata",
+			tag:            "v1.0.0+rc",
+			preReleaseName: "rc",
+			expected:       []string{"pre-release name 'rc' is used as build metadata by tag 'v1.0.0+rc', it may be misconfigured"},
+		},
+		{
+			name:           "name matches a metadata identifier",
+			tag:            "v1.0.0+build.5.beta",
+			preReleaseName: "beta",
+			expected:       []string{"pre-release name 'beta' is used as build metadata by tag 'v1.0.0+build.5.beta', it may be misconfigured"},
+		},
+		{
+			name:           "name is part of an identifier",
+			tag:            "v1.0.0+rc2",
+			preReleaseName: "rc",
+		},
+		{
+			name: "no pre-release name",
+			tag:  "v1.0.0+rc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, tc.tag, repo)
+			updateReadme(t, repo, "fix: thing")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:       repo.Path(),
+				Branch:         "main",
+				PreReleaseName: tc.preReleaseName,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.Warnings())
+		})
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	t.Setenv("AUTOTAG_SCHEME", "conventional")
+
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "release-1.0.0", repo)
+	updateReadme(t, repo, "feat: new feature")
+	runGit(t, repo, "config", "autotag.prefix", "release-")
+	runGit(t, repo, "config", "autotag.preReleaseName", "rc")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:                  repo.Path(),
+		PreReleaseName:            "beta",
+		PreReleaseTimestampLayout: "datetime",
+	})
+	checkFatal(t, err)
+
+	cfg := r.EffectiveConfig()
+	assert.Equal(t, "main", cfg.Branch)
+	assert.Equal(t, "conventional", cfg.Scheme)
+	assert.Equal(t, "release-", cfg.TagPrefix)
+	assert.Equal(t, "beta", cfg.PreReleaseName)
+	assert.Equal(t, datetimeTsLayout, cfg.PreReleaseTimestampLayout)
+	assert.Equal(t, defaultTagRefNamespace, cfg.TagRefNamespace)
+	assert.Equal(t, "_", cfg.DockerTagSeparator)
+	assert.Equal(t, "origin", cfg.Remote)
+	assert.Equal[VersionStrategy](t, SemVerStrategy{}, cfg.VersionStrategy)
+	assert.NotZero(t, cfg.TagFormatter)
+	assert.Equal(t, "1.1.0-beta.20190101000000", r.LatestVersion())
+}
+
+func TestEffectiveConfigDefaultScheme(t *testing.T) {
+	t.Setenv("AUTOTAG_SCHEME", "")
+
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "fix: thing")
+
+	r, err := NewRepo(GitRepoConfig{RepoPath: repo.Path(), Branch: "main", Scheme: "autotag, conventional"})
+	checkFatal(t, err)
+	assert.Equal(t, "autotag,conventional", r.EffectiveConfig().Scheme)
+
+	r, err = NewRepo(GitRepoConfig{RepoPath: repo.Path(), Branch: "main"})
+	checkFatal(t, err)
+	assert.Equal(t, "autotag", r.EffectiveConfig().Scheme)
+}
+
+func TestCreatedRef(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "[minor] new feature",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	assert.Equal(t, "", r.CreatedRef())
+	_, err = r.AutoTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/tags/v1.1.0", r.CreatedRef())
+	assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-parse", r.CreatedRef()))
+}
+
+func TestAutoTagResult(t *testing.T) {
+	tests := []struct {
+		name          string
+		disablePrefix bool
+		tagPrefix     string
+		expected      string
+	}{
+		{
+			name:     "default prefix",
+			expected: "v1.1.0",
+		},
+		{
+			name:          "without prefix",
+			disablePrefix: true,
+			expected:      "1.1.0",
+		},
+		{
+			name:      "tag prefix",
+			tagPrefix: "release-",
+			expected:  "release-1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag:    tc.tagPrefix + "1.0.0",
+				disablePrefix: tc.disablePrefix,
+				tagPrefix:     tc.tagPrefix,
+				nextCommit:    "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			result, err := r.AutoTag()
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, result.Tag)
+			assert.Equal(t, "1.1.0", result.Version.String())
+			assert.Equal(t, r.branchID, result.SHA)
+			assert.Equal(t, result.SHA, runGit(t, r.repo, "rev-parse", result.Tag+"^{commit}"))
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		commitList: []string{"[patch] fix", "[minor] new feature"},
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	before, err := r.repo.Tags()
+	checkFatal(t, err)
+
+	manifest := filepath.Join(t.TempDir(), "releases.json")
+	state := filepath.Join(t.TempDir(), "autotag.state")
+	r.dryRun = true
+	r.manifestFile = manifest
+	r.stateFile = state
+	r.floatingAliases = true
+	r.createIntermediateTags = true
+	r.confirm = func(string) (bool, error) {
+		t.Fatal("confirm called in dry run")
+		return false, nil
+	}
+	result, err := r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, "v1.1.0", result.Tag)
+	assert.Equal(t, r.branchID, result.SHA)
+	assert.Equal(t, "", r.CreatedRef())
+
+	after, err := r.repo.Tags()
+	checkFatal(t, err)
+	assert.Equal(t, before, after)
+	_, err = os.Stat(manifest)
+	assert.IsError(t, err, fs.ErrNotExist)
+	_, err = os.Stat(state)
+	assert.IsError(t, err, fs.ErrNotExist)
+
+	// the checks on the tag still apply
+	r.tagPattern = "release-*"
+	_, err = r.AutoTag()
+	assert.EqualError(t, err, "tag 'v1.1.0' does not match tag pattern 'release-*'")
+}
+
+func TestDryRunValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    string
+		expectedErr string
+	}{
+		{
+			name: "tag can be created",
+		},
+		{
+			name:        "tag exists",
+			existing:    "v1.1.0",
+			expectedErr: "tag 'v1.1.0' already exists",
+		},
+		{
+			name:        "ref is locked",
+			existing:    "lock",
+			expectedErr: "cannot lock ref 'refs/tags/v1.1.0'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			switch tc.existing {
+			case "lock":
+				// another writer holds the ref
+				checkFatal(t, os.WriteFile(filepath.Join(r.repo.Path(), "refs", "tags", "v1.1.0.lock"), nil, 0o644))
+			case "":
+			default:
+				// the tag was created, eg: by another pipeline, on a commit that is not the branch head
+				runGit(t, r.repo, "tag", tc.existing, "HEAD~1")
+			}
+
+			before, err := r.repo.Tags()
+			checkFatal(t, err)
+
+			r.dryRun = true
+			r.dryRunValidate = true
+			result, err := r.AutoTag()
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			} else {
+				checkFatal(t, err)
+				assert.Equal(t, "v1.1.0", result.Tag)
+			}
+
+			after, err := r.repo.Tags()
+			checkFatal(t, err)
+			assert.Equal(t, before, after)
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	tests := []struct {
+		name        string
+		remote      string
+		expectedErr string
+	}{
+		{
+			name: "default remote",
+		},
+		{
+			name:   "named remote",
+			remote: "upstream",
+		},
+		{
+			name:        "missing remote",
+			remote:      "missing",
+			expectedErr: "error pushing 'v1.1.0' to remote 'missing', the tags were created locally",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			remote := filepath.Join(t.TempDir(), "remote.git")
+			runGit(t, r.repo, "init", "--bare", remote)
+			name := tc.remote
+			if name == "" {
+				name = "origin"
+			}
+			if name != "missing" {
+				runGit(t, r.repo, "remote", "add", name, remote)
+			}
+
+			r.push = true
+			r.remote = name
+			_, err = r.AutoTag()
+			// the local tag exists whether or not the push succeeds
+			assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-parse", "v1.1.0"))
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, r.branchID+"\trefs/tags/v1.1.0", runGit(t, r.repo, "ls-remote", "--tags", name))
+		})
+	}
+}
+
+func TestPushTag(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, r.repo, "init", "--bare", remote)
+	runGit(t, r.repo, "remote", "add", "origin", remote)
+
+	checkFatal(t, r.PushTag("v1.0.0"))
+	assert.Contains(t, runGit(t, r.repo, "ls-remote", "--tags", "origin"), "refs/tags/v1.0.0")
+
+	err = r.PushTag("v9.9.9")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "created locally")
+}
+
+func TestPushCreatedTags(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, repo, "init", "--bare", remote)
+	runGit(t, repo, "remote", "add", "origin", remote)
+
+	// the aliases of the previous release are on the remote already
+	makeTag(repo, "v1")
+	makeTag(repo, "v1.0")
+	runGit(t, repo, "push", "-q", "origin", "--tags")
+
+	updateReadme(t, repo, "[patch] a fix")
+	patchID := runGit(t, repo, "rev-parse", "HEAD")
+	updateReadme(t, repo, "[minor] new feature")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:               repo.Path(),
+		Branch:                 "main",
+		Prefix:                 true,
+		CreateIntermediateTags: true,
+		FloatingAliases:        true,
+		DualTag:                true,
+		Push:                   true,
+	})
+	checkFatal(t, err)
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+
+	remoteTags := runGit(t, repo, "ls-remote", "--tags", "origin")
+	for _, tag := range []string{"v1.0.1", "v1.1.0", "release/v1.1.0", "v1", "v1.1"} {
+		assert.Contains(t, remoteTags, "refs/tags/"+tag)
+	}
+	assert.Contains(t, remoteTags, patchID+"\trefs/tags/v1.0.1")
+	assert.Contains(t, remoteTags, r.branchID+"\trefs/tags/v1\n")
+}
+
+func TestDualTag(t *testing.T) {
+	tests := []struct {
+		name             string
+		releaseTagPrefix string
+		tagMessage       string
+		expected         string
+		expectedMessage  string
+	}{
+		{
+			name:            "default release tag prefix",
+			expected:        "release/v1.1.0",
+			expectedMessage: "1.1.0",
+		},
+		{
+			name:             "custom release tag prefix",
+			releaseTagPrefix: "rel-",
+			expected:         "rel-v1.1.0",
+			expectedMessage:  "1.1.0",
+		},
+		{
+			name:            "tag message",
+			tagMessage:      "Release {{.Version}}",
+			expected:        "release/v1.1.0",
+			expectedMessage: "Release 1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.dualTag = true
+			r.tagMessage = tc.tagMessage
+			if tc.releaseTagPrefix != "" {
+				r.releaseTagPrefix = tc.releaseTagPrefix
+			}
+
+			result, err := r.AutoTag()
+			checkFatal(t, err)
+			assert.Equal(t, "v1.1.0", result.Tag)
+			assert.Equal(t, tc.expected, result.ReleaseTag)
+
+			// the version tag is lightweight and the release tag annotated, both on the branch head
+			assert.Equal(t, "commit", runGit(t, r.repo, "cat-file", "-t", "refs/tags/v1.1.0"))
+			assert.Equal(t, "tag", runGit(t, r.repo, "cat-file", "-t", "refs/tags/"+tc.expected))
+			assert.Equal(t, tc.expectedMessage, runGit(t, r.repo, "for-each-ref", "--format=%(contents:subject)", "refs/tags/"+tc.expected))
+			assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-parse", "v1.1.0^{commit}"))
+			assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-parse", tc.expected+"^{commit}"))
+		})
+	}
+}
+
+func TestDualTagChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		tagPattern  string
+		branch      string
+		existing    string
+		expectedErr string
+	}{
+		{
+			name:        "tag pattern",
+			tagPattern:  "v*",
+			expectedErr: "tag 'release/v1.1.0' does not match tag pattern 'v*'",
+		},
+		{
+			name:        "ref collision",
+			branch:      "release/v1.1.0",
+			expectedErr: "tag 'release/v1.1.0' has the same name as a branch",
+		},
+		{
+			name:        "dry run validate",
+			existing:    "release/v1.1.0",
+			expectedErr: "tag 'release/v1.1.0' already exists",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.dualTag = true
+			r.tagPattern = tc.tagPattern
+			if tc.branch != "" {
+				runGit(t, r.repo, "branch", tc.branch)
+				r.checkRefCollision = true
+			}
+			if tc.existing != "" {
+				runGit(t, r.repo, "tag", tc.existing, "v1.0.0")
+				r.dryRun = true
+				r.dryRunValidate = true
+			}
+			_, err = r.AutoTag()
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestDualTagSign(t *testing.T) {
+	key := setupGPG(t)
+
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "[minor] new feature",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	r.dualTag = true
+	r.sign = true
+	r.signingKey = key
+	result, err := r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, "release/v1.1.0", result.ReleaseTag)
+	runGit(t, r.repo, "verify-tag", "v1.1.0")
+	runGit(t, r.repo, "verify-tag", "release/v1.1.0")
+}
+
+func TestAutoTagResultNoBump(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "a fix",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	r.noBump = true
+	result, err := r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, AutoTagResult{}, result)
+}
+
+func TestMultipleSchemes(t *testing.T) {
+	tests := []struct {
+		name        string
+		scheme      string
+		message     string
+		strictMatch bool
+		expected    string
+		rule        string
+		shouldErr   bool
+	}{
+		{
+			name:     "conventional only",
+			scheme:   "conventional",
+			message:  "feat: add thing [major]",
+			expected: "1.1.0",
+		},
+		{
+			name:     "autotag bump is higher",
+			scheme:   "autotag,conventional",
+			message:  "feat: add thing [major]",
+			expected: "2.0.0",
+			rule:     "matched majorRex via `[major]`",
+		},
+		{
+			name:     "conventional bump is higher",
+			scheme:   "autotag, conventional",
+			message:  "fix!: drop the old API #minor",
+			expected: "2.0.0",
+			rule:     "conventional type `fix!` -> major",
+		},
+		{
+			name:     "only one scheme matches",
+			scheme:   "conventional,autotag",
+			message:  "feat: add thing",
+			expected: "1.1.0",
+			rule:     "conventional type `feat` -> minor",
+		},
+		{
+			name:        "no scheme matches with strict match",
+			scheme:      "autotag,conventional",
+			message:     "add thing",
+			strictMatch: true,
+			shouldErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, tc.message)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:    repo.Path(),
+				Branch:      "main",
+				Scheme:      tc.scheme,
+				StrictMatch: tc.strictMatch,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+			if tc.rule != "" {
+				assert.Equal(t, tc.rule, r.MatchDetails()[0].Rule)
+			}
+		})
+	}
+}
+
+func TestEmptyMessagePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		strictMatch bool
+		feature     bool
+		message     string
+		note        string
+		expected    string
+		rule        string
+		expectedErr string
+	}{
+		{
+			name:     "default",
+			expected: "1.0.1",
+			rule:     "no bump directive",
+		},
+		{
+			name:        "default with strict match",
+			strictMatch: true,
+			expectedErr: "no match found for commit",
+		},
+		{
+			name:        "patch with strict match",
+			policy:      "patch",
+			strictMatch: true,
+			expected:    "1.0.1",
+			rule:        "empty message -> patch",
+		},
+		{
+			name:     "patch with whitespace message",
+			policy:   "patch",
+			message:  "  \n\t",
+			expected: "1.0.1",
+			rule:     "empty message -> patch",
+		},
+		{
+			name:        "skip with strict match",
+			policy:      "skip",
+			strictMatch: true,
+			feature:     true,
+			expected:    "1.1.0",
+		},
+		{
+			name:        "error",
+			policy:      "error",
+			expectedErr: "has an empty message",
+		},
+		{
+			name:     "bump note with patch",
+			policy:   "patch",
+			note:     "bump=major",
+			expected: "2.0.0",
+			rule:     "note `bump=major`",
+		},
+		{
+			name:     "bump note with skip",
+			policy:   "skip",
+			note:     "bump=major",
+			expected: "2.0.0",
+			rule:     "note `bump=major`",
+		},
+		{
+			name:     "bump note with error",
+			policy:   "error",
+			note:     "bump=major",
+			expected: "2.0.0",
+			rule:     "note `bump=major`",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			if tc.feature {
+				updateReadme(t, repo, "[minor] new feature")
+			}
+			runGit(t, repo, "commit", "--allow-empty", "--allow-empty-message", "--cleanup=verbatim", "-m", tc.message)
+			if tc.note != "" {
+				runGit(t, repo, "notes", "--ref", "bumps", "add", "-m", tc.note, "HEAD")
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:           repo.Path(),
+				Branch:             "main",
+				StrictMatch:        tc.strictMatch,
+				EmptyMessagePolicy: tc.policy,
+				BumpNotesRef:       "bumps",
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+			if tc.rule != "" {
+				assert.Equal(t, tc.rule, r.MatchDetails()[0].Rule)
+			}
+		})
+	}
+}
+
+func TestBumpTrailerKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		expected    string
+		rule        string
+		expectedErr string
+	}{
+		{
+			name:     "trailer takes precedence",
+			message:  "feat: add thing\n\nSemver-Bump: patch",
+			expected: "1.0.1",
+			rule:     "trailer `Semver-Bump: patch`",
+		},
+		{
+			name:     "trailer key is case insensitive",
+			message:  "fix: drop the old API\n\nsemver-bump: Major",
+			expected: "2.0.0",
+			rule:     "trailer `semver-bump: Major`",
+		},
+		{
+			name:     "trailer absent",
+			message:  "feat: add thing",
+			expected: "1.1.0",
+			rule:     "conventional type `feat` -> minor",
+		},
+		{
+			name:     "other trailer",
+			message:  "feat: add thing\n\nAutotag-Bump: patch",
+			expected: "1.1.0",
+			rule:     "conventional type `feat` -> minor",
+		},
+		{
+			name:        "invalid trailer value",
+			message:     "feat: add thing\n\nSemver-Bump: huge",
+			expectedErr: "bump trailer 'huge' is not valid; must be (major|minor|patch)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, tc.message)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:       repo.Path(),
+				Branch:         "main",
+				Scheme:         "conventional",
+				BumpTrailerKey: "Semver-Bump",
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+			assert.Equal(t, tc.rule, r.MatchDetails()[0].Rule)
+		})
+	}
+}
+
+func TestBumpNotesRef(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		note        string
+		notesRef    string
+		expected    string
+		rule        string
+		expectedErr string
+	}{
+		{
+			name:     "note downgrades the bump",
+			message:  "[major] drop the old API",
+			note:     "bump=patch",
+			expected: "1.0.1",
+			rule:     "note `bump=patch`",
+		},
+		{
+			name:     "note upgrades the bump",
+			message:  "fix typo",
+			note:     "reviewed, this changes the API\nbump=minor",
+			expected: "1.1.0",
+			rule:     "note `bump=minor`",
+		},
+		{
+			name:     "full notes ref",
+			message:  "fix typo",
+			note:     "bump=major",
+			notesRef: "refs/notes/bumps",
+			expected: "2.0.0",
+			rule:     "note `bump=major`",
+		},
+		{
+			name:     "note without a bump",
+			message:  "[minor] add thing",
+			note:     "reviewed",
+			expected: "1.1.0",
+		},
+		{
+			name:     "missing notes ref",
+			message:  "[minor] add thing",
+			note:     "bump=major",
+			notesRef: "other",
+			expected: "1.1.0",
+		},
+		{
+			name:        "invalid note value",
+			message:     "fix typo",
+			note:        "bump=huge",
+			expectedErr: "bump note 'huge' is not valid; must be (major|minor|patch)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, tc.message)
+			runGit(t, repo, "notes", "--ref", "bumps", "add", "-m", tc.note, "HEAD")
+
+			notesRef := tc.notesRef
+			if notesRef == "" {
+				notesRef = "bumps"
+			}
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:     repo.Path(),
+				Branch:       "main",
+				BumpNotesRef: notesRef,
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+			if tc.rule != "" {
+				assert.Equal(t, tc.rule, r.MatchDetails()[0].Rule)
+			}
+		})
+	}
+}
+
+func TestReadBumpNotes(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	// a note before the base tag is not read
+	updateReadme(t, repo, "old change")
+	runGit(t, repo, "notes", "--ref", "bumps", "add", "-m", "bump=major", "HEAD")
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "fix typo")
+	runGit(t, repo, "notes", "--ref", "bumps", "add", "-m", "bump=patch", "HEAD")
+	first := runGit(t, repo, "rev-parse", "HEAD")
+	updateReadme(t, repo, "another change")
+	updateReadme(t, repo, "rename the API")
+	runGit(t, repo, "notes", "--ref", "bumps", "add", "-m", "reviewed\n\nbump=minor", "HEAD")
+	last := runGit(t, repo, "rev-parse", "HEAD")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:     repo.Path(),
+		Branch:       "main",
+		BumpNotesRef: "bumps",
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+	assert.Equal(t, map[string]string{first: "bump=patch\n", last: "reviewed\n\nbump=minor\n"}, r.bumpNotes)
+}
+
+func TestTagRefNamespace(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	// a higher version is stored in the custom namespace only
+	runGit(t, r.repo, "update-ref", "refs/release-tags/v2.0.0", "HEAD")
+	updateReadme(t, r.repo, "#minor new feature")
+
+	nr, err := NewRepo(GitRepoConfig{
+		RepoPath:        repoRoot(r.repo),
+		Branch:          "main",
+		Prefix:          true,
+		TagRefNamespace: "refs/release-tags",
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "2.1.0", nr.LatestVersion())
+	_, err = nr.AutoTag()
+	assert.NoError(t, err)
+
+	refs := runGit(t, r.repo, "for-each-ref", "--format=%(refname)", "refs/release-tags/")
+	assert.Equal(t, "refs/release-tags/v2.0.0\nrefs/release-tags/v2.1.0", refs)
+	assert.Equal(t, "refs/release-tags/v2.1.0", nr.CreatedRef())
+
+	tags, err := r.repo.Tags()
+	checkFatal(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, tags)
+}
+
+func TestEmptyBranch(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	_, err = NewRepo(GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+	})
+	assert.IsError(t, err, ErrEmptyBranch)
+}
+
+func TestAutoTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       testRepoSetup
+		shouldErr   bool
+		expectedTag string
+	}{
+		// tests for autotag (default) scheme
+		{
+			name: "autotag scheme, [major] bump",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "[major] this is a big release\n\nfoo bar baz\n",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "autotag scheme, [minor] bump",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "[minor] this is a smaller release\n\nfoo bar baz\n",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.1.0",
+		},
+		{
+			name: "autotag scheme, patch bump",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "this is just a basic change\n\nfoo bar baz\n",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.0.1",
+		},
+		{
+			name: "autotag scheme, #major bump",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "#major this is a big release\n\nfoo bar baz\n",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "autotag scheme, #minor bump",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "#minor this is a smaller release\n\nfoo bar baz\n",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.1.0",
+		},
+		{
+			name: "pre-release-name with patch bump",
+			setup: testRepoSetup{
+				scheme:         "autotag",
+				nextCommit:     "#patch bump",
+				initialTag:     "v1.0.0",
+				preReleaseName: "dev",
+			},
+			expectedTag: "v1.0.1-dev",
+		},
+		{
+			name: "epoch pre-release-timestamp",
+			setup: testRepoSetup{
+				scheme:                    "autotag",
+				nextCommit:                "#patch bump",
+				initialTag:                "v1.0.0",
+				preReleaseTimestampLayout: "epoch",
+			},
+			expectedTag: fmt.Sprintf("v1.0.1-%d", timeNow().UTC().Unix()),
+		},
+		{
+			name: "datetime pre-release-timestamp",
+			setup: testRepoSetup{
+				scheme:                    "autotag",
+				nextCommit:                "#patch bump",
+				initialTag:                "v1.0.0",
+				preReleaseTimestampLayout: "datetime",
+			},
+			expectedTag: fmt.Sprintf("v1.0.1-%s", timeNow().Format(datetimeTsLayout)),
+		},
+		{
+			name: "epoch pre-release-timestamp and pre-release-name",
+			setup: testRepoSetup{
+				scheme:                    "autotag",
+				nextCommit:                "#patch bump",
+				initialTag:                "v1.0.0",
+				preReleaseName:            "dev",
+				preReleaseTimestampLayout: "epoch",
+			},
+			expectedTag: fmt.Sprintf("v1.0.1-dev.%d", timeNow().UTC().Unix()),
+		},
+		{
+			name: "ignore non-conforming tags",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "#patch bump",
+				initialTag: "v1.0.0",
+				extraTags:  []string{"foo", ""},
+			},
+			expectedTag: "v1.0.1",
+		},
+		{
+			name: "test with pre-relase tag",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				nextCommit: "#patch bump",
+				initialTag: "v1.0.0",
+				extraTags:  []string{"v1.0.1-pre"},
+			},
+			expectedTag: "v1.0.1",
+		},
+		{
+			name: "build metadata",
+			setup: testRepoSetup{
+				scheme:        "autotag",
+				nextCommit:    "#patch bump",
+				initialTag:    "v1.0.0",
+				buildMetadata: "g012345678",
+			},
+			expectedTag: "v1.0.1+g012345678",
+		},
+		{
+			name: "autotag scheme, [major] bump without prefix",
+			setup: testRepoSetup{
+				scheme:        "autotag",
+				nextCommit:    "[major] this is a big release\n\nfoo bar baz\n",
+				initialTag:    "1.0.0",
+				disablePrefix: true,
+			},
+			expectedTag: "2.0.0",
+		},
+		{
+			name: "autotag scheme, Bump with Major with interstitial minor changes",
+			setup: testRepoSetup{
+				scheme:        "autotag",
+				initialTag:    "1.0.0",
+				disablePrefix: true,
+				commitList: []string{
+					"#patch: thing 1",
+					"[minor]: break thing 1",
+					"feat: thing 2",
+					"[major]: drop support for Node 6",
+				},
+			},
+			expectedTag: "2.0.0",
+		},
+		{
+			name: "autotag scheme, Bump with Major between minor changes",
+			setup: testRepoSetup{
+				scheme:        "autotag",
+				initialTag:    "1.0.0",
+				disablePrefix: true,
+				commitList: []string{
+					"[minor]: thing 1",
+					"[major]: drop support for Node 6",
+					"[minor]: thing 2",
+				},
+			},
+			expectedTag: "2.0.0",
+		},
+		{
+			name: "autotag scheme, version comparison is not lexicographic",
+			setup: testRepoSetup{
+				scheme:     "autotag",
+				initialTag: "v0.9.0",
+				commitList: []string{
+					"[minor]: thing 1",
+					"[minor]: thing 2",
+				},
+			},
+			expectedTag: "v0.10.0",
+		},
+
+		// tests for conventional commits scheme. Based on:
+		// https://www.conventionalcommits.org/en/v1.0.0/#summary
+		// and
+		// https://www.conventionalcommits.org/en/v1.0.0/#examples
+		{
+			name: "conventional commits, minor bump without scope",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "feat: allow provided config object to extend other configs",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.1.0",
+		},
+		{
+			name: "conventional commits, minor bump with scope",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "feat(lang): add polish language",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.1.0",
+		},
+		{
+			name: "conventional commits, breaking change via ! appended to type",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "refactor!: drop support for Node 6",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "conventional commits, breaking change via ! appended to type/scope",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "refactor(runtime)!: drop support for Node 6",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "conventional commits, breaking change via footer",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "feat: allow provided config object to extend other configs\n\nbody before footer\n\nBREAKING CHANGE: non-backwards compatible",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "conventional commits, patch/minor bump",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "fix: correct minor typos in code",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.0.1",
+		},
+		{
+			name: "conventional commits, non-conforming fallback to patch bump",
+			setup: testRepoSetup{
+				scheme:     "conventional",
+				nextCommit: "not a conventional commit message",
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v1.0.1",
+		},
+		{
+			name: "conventional commits, breaking change with minor interstitial commits",
+			setup: testRepoSetup{
+				scheme: "conventional",
+				commitList: []string{
+					"feat: thing 1",
+					"feat!: break thing 1",
+					"feat: thing 2",
+					"refactor(runtime)!: drop support for Node 6",
+				},
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "conventional commits, breaking change between minor commits",
+			setup: testRepoSetup{
+				scheme: "conventional",
+				commitList: []string{
+					"feat: thing 1",
+					"feat!: break thing 1",
+					"feat: thing 2",
+				},
+				initialTag: "v1.0.0",
+			},
+			expectedTag: "v2.0.0",
+		},
+		{
+			name: "conventional commits, version comparison is not lexicographic",
+			setup: testRepoSetup{
+				scheme: "conventional",
+				commitList: []string{
+					"feat: thing 1",
+					"feat: thing 2",
+				},
+				initialTag: "v0.9.0",
+			},
+			expectedTag: "v0.10.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, tc.setup)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			_, err = r.AutoTag()
+			if tc.shouldErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			tags, err := r.repo.Tags()
+			checkFatal(t, err)
+			assert.SliceContains(t, tags, tc.expectedTag)
+		})
+	}
+}
+
+func TestTagPrefixTemplate(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "#minor nightly feature",
+		tagPrefix:  "nightly-{date}-",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	_, err = r.AutoTag()
+	assert.NoError(t, err)
+
+	tags, err := r.repo.Tags()
+	checkFatal(t, err)
+	assert.SliceContains(t, tags, "nightly-20190101-1.1.0")
+
+	// the date-templated tag is parsed back as the base for the next version
+	updateReadme(t, r.repo, "another nightly change")
+	next, err := NewRepo(GitRepoConfig{
+		RepoPath:  repoRoot(r.repo),
+		Branch:    "main",
+		TagPrefix: "nightly-{date}-",
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", next.currentVersion.String())
+	assert.Equal(t, "nightly-20190101-1.1.0", next.currentTagName)
+	assert.Equal(t, "1.1.1", next.LatestVersion())
+}
+
+func TestTagPrefixDateMidnight(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[patch] a fix")
+	updateReadme(t, repo, "[minor] new feature")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:               repo.Path(),
+		Branch:                 "main",
+		TagPrefix:              "nightly-{date}-",
+		CreateIntermediateTags: true,
+	})
+	checkFatal(t, err)
+
+	// the run crosses midnight after NewRepo, every tag keeps the date of the run
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time {
+		return time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	}
+	assert.Equal(t, "Bumping v1.0.0 → nightly-20190101-1.1.0 (minor)", r.BumpMessage())
+	result, err := r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, "nightly-20190101-1.1.0", result.Tag)
+	assert.Equal(t, "nightly-20190101-1.0.1\nnightly-20190101-1.1.0\nv1.0.0", runGit(t, repo, "tag", "--list"))
+}
+
+func TestTagPrefixRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		tagPrefix string
+		prefix    bool
+		tag       string
+	}{
+		{
+			name:      "release prefix",
+			tagPrefix: "release-",
+			tag:       "release-1.1.0",
+		},
+		{
+			name:      "monorepo path prefix",
+			tagPrefix: "api/v",
+			tag:       "api/v1.1.0",
+		},
+		{
+			name:      "tag prefix wins over prefix",
+			tagPrefix: "release-",
+			prefix:    true,
+			tag:       "release-1.1.0",
+		},
+		{
+			name:   "prefix",
+			prefix: true,
+			tag:    "v1.1.0",
+		},
+		{
+			name: "empty prefix",
+			tag:  "1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag:    strings.TrimSuffix(tc.tag, "1.1.0") + "1.0.0",
+				disablePrefix: !tc.prefix,
+				tagPrefix:     tc.tagPrefix,
+				nextCommit:    "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			result, err := r.AutoTag()
+			checkFatal(t, err)
+			assert.Equal(t, tc.tag, result.Tag)
+
+			// the new tag is parsed back as the base for the next version
+			updateReadme(t, r.repo, "another change")
+			next, err := NewRepo(GitRepoConfig{
+				RepoPath:  repoRoot(r.repo),
+				Branch:    "main",
+				Prefix:    tc.prefix,
+				TagPrefix: tc.tagPrefix,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.tag, next.currentTagName)
+			assert.Equal(t, "1.1.1", next.LatestVersion())
+		})
+	}
+}
+
+func TestPathScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		pathScope   string
+		tagPrefix   string
+		prefix      bool
+		skip        bool
+		expected    string
+		expectedTag string
+	}{
+		{
+			name:        "frontend",
+			pathScope:   "frontend",
+			prefix:      true,
+			expected:    "1.1.0",
+			expectedTag: "frontend/v1.1.0",
+		},
+		{
+			name:        "backend",
+			pathScope:   "backend/",
+			prefix:      true,
+			expected:    "3.0.0",
+			expectedTag: "backend/v3.0.0",
+		},
+		{
+			name:        "without prefix",
+			pathScope:   "docs",
+			expected:    "0.1.1",
+			expectedTag: "docs/0.1.1",
+		},
+		{
+			name:        "tag prefix",
+			pathScope:   "backend",
+			tagPrefix:   "backend-",
+			expected:    "1.0.0",
+			expectedTag: "backend-1.0.0",
+		},
+		{
+			name:      "no commits touching the path",
+			pathScope: "cli",
+			prefix:    true,
+			skip:      true,
+			expected:  "0.5.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v5.0.0", repo)
+			for _, tag := range []string{"frontend/v1.0.0", "backend/v2.0.0", "docs/0.1.0", "backend-0.1.0", "cli/v0.5.0"} {
+				makeTag(repo, tag)
+			}
+			for dir, message := range map[string]string{"frontend": "[minor] frontend feature", "backend": "[major] backend rewrite", "docs": "fix typo"} {
+				checkFatal(t, os.MkdirAll(filepath.Join(repoRoot(repo), dir), 0o755))
+				checkFatal(t, os.WriteFile(filepath.Join(repoRoot(repo), dir, "README"), []byte(message), 0o644))
+				makeCommit(repo, message)
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:             repo.Path(),
+				Branch:               "main",
+				Prefix:               tc.prefix,
+				TagPrefix:            tc.tagPrefix,
+				PathScope:            tc.pathScope,
+				SkipWhenNothingToTag: tc.skip,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+
+			result, err := r.AutoTag()
+			checkFatal(t, err)
+			assert.Equal(t, tc.expectedTag, result.Tag)
+			if tc.expectedTag != "" {
+				assert.Equal(t, r.branchID, runGit(t, repo, "rev-parse", tc.expectedTag+"^{commit}"))
+			}
+		})
+	}
+}
+
+func TestPathScopeChangeHeuristics(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	makeTag(repo, "frontend/v1.0.0")
+	for _, dir := range []string{"frontend", "backend"} {
+		checkFatal(t, os.MkdirAll(filepath.Join(repoRoot(repo), dir), 0o755))
+	}
+	checkFatal(t, os.WriteFile(filepath.Join(repoRoot(repo), "frontend", "README"), []byte("ui"), 0o644))
+	makeCommit(repo, "tweak the ui")
+	// a large change outside of the scope, to a breaking path
+	for i := 0; i < 20; i++ {
+		checkFatal(t, os.WriteFile(filepath.Join(repoRoot(repo), "backend", fmt.Sprintf("schema%d.sql", i)), []byte("create table t;"), 0o644))
+	}
+	makeCommit(repo, "rewrite the backend schema")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:         repo.Path(),
+		Branch:           "main",
+		Prefix:           true,
+		PathScope:        "frontend",
+		ChangeHeuristics: true,
+		BreakingPaths:    []string{"backend"},
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "1.0.1", r.LatestVersion())
+}
+
+func TestTagPrefixInvalidRef(t *testing.T) {
+	for _, prefix := range []string{"nightly {date}-", "nightly..{date}-"} {
+		t.Run(prefix, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "#patch bump",
+				tagPrefix:  prefix,
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			_, err = r.AutoTag()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTagNewVersionRefName(t *testing.T) {
+	tests := []struct {
+		name          string
+		tagPrefix     string
+		buildMetadata string
+		expectedErr   string
+	}{
+		{
+			name:        "space",
+			tagPrefix:   "nightly {date}-",
+			expectedErr: "tag 'nightly 20190101-1.0.1' is not a valid git ref: must not contain ' '",
+		},
+		{
+			name:        "double dot",
+			tagPrefix:   "nightly..{date}-",
+			expectedErr: "tag 'nightly..20190101-1.0.1' is not a valid git ref: must not contain '..'",
+		},
+		{
+			name:        "lock component",
+			tagPrefix:   "release.lock/",
+			expectedErr: "component 'release.lock' must not end with '.lock'",
+		},
+		{
+			name:          "over-long",
+			buildMetadata: strings.Repeat("a", 260),
+			expectedErr:   "component is 267 bytes, exceeds the maximum of 255",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag:    "v1.0.0",
+				nextCommit:    "#patch bump",
+				tagPrefix:     tc.tagPrefix,
+				buildMetadata: tc.buildMetadata,
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			_, err = r.AutoTag()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
+
+func TestCreatedTagNamesRefName(t *testing.T) {
+	long := strings.Repeat("r", 250) + "-"
+	tests := []struct {
+		name        string
+		cfg         GitRepoConfig
+		expectedErr string
+	}{
+		{
+			name:        "release tag",
+			cfg:         GitRepoConfig{DualTag: true, ReleaseTagPrefix: long},
+			expectedErr: "component is 257 bytes, exceeds the maximum of 255",
+		},
+		{
+			name:        "intermediate tags",
+			cfg:         GitRepoConfig{CreateIntermediateTags: true, TagPrefix: long},
+			expectedErr: "tag '" + long + "1.0.1' is not a valid git ref: component is 256 bytes, exceeds the maximum of 255",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "[patch] a fix")
+			updateReadme(t, repo, "[minor] new feature")
+
+			tc.cfg.RepoPath = repo.Path()
+			tc.cfg.Branch = "main"
+			tc.cfg.Prefix = true
+			r, err := NewRepo(tc.cfg)
+			checkFatal(t, err)
+
+			_, err = r.AutoTag()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+			// no tag is written when any of the names is invalid
+			assert.Equal(t, "v1.0.0", runGit(t, repo, "tag", "--list"))
+		})
+	}
+}
+
+func TestValidateRefName(t *testing.T) {
+	for _, ref := range []string{"refs/tags/v1.0.0", "refs/tags/v1.0.0-rc.1+build.5", "refs/tags/release/v1.0.0"} {
+		assert.NoError(t, validateRefName(ref), ref)
+	}
+	for _, ref := range []string{"@", "refs/tags/v1.0.0.", "refs/tags/.v1", "refs/tags/v1@{0}", "refs/tags/v1~1", "refs/tags/v1\t", "refs/tags//v1"} {
+		assert.Error(t, validateRefName(ref), ref)
+	}
+}
+
+func TestTagFormatter(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "[minor] new feature",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	// a branch scoped release shape the default formatter can't produce
+	formatter := func(v *version.Version, cfg GitRepoConfig) (string, error) {
+		return fmt.Sprintf("%s/release-%s", cfg.Branch, cfg.VersionStrategy.Format(v)), nil
+	}
+	next, err := NewRepo(GitRepoConfig{
+		RepoPath:     repoRoot(r.repo),
+		Branch:       "main",
+		TagFormatter: formatter,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "Bumping v1.0.0 → main/release-1.1.0 (minor)", next.BumpMessage())
+
+	_, err = next.AutoTag()
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/tags/main/release-1.1.0", next.CreatedRef())
+	assert.Equal(t, "main/release-1.1.0\nv1.0.0", runGit(t, r.repo, "tag", "--list"))
+
+	_, err = NewRepo(GitRepoConfig{
+		RepoPath: repoRoot(r.repo),
+		Branch:   "main",
+		TagFormatter: func(*version.Version, GitRepoConfig) (string, error) {
+			return "", errors.New("no release shape")
+		},
+	})
+	assert.EqualError(t, err, "error formatting the tag name of '1.1.0': no release shape")
+}
+
+func TestDefaultTagFormatter(t *testing.T) {
+	v := version.Must(version.NewVersion("1.2.3-rc.1"))
+	tests := []struct {
+		cfg      GitRepoConfig
+		expected string
+	}{
+		{cfg: GitRepoConfig{}, expected: "1.2.3-rc.1"},
+		{cfg: GitRepoConfig{Prefix: true}, expected: "v1.2.3-rc.1"},
+		{cfg: GitRepoConfig{Prefix: true, TagPrefix: "nightly-{date}-"}, expected: "nightly-20190101-1.2.3-rc.1"},
+	}
+	for _, tc := range tests {
+		name, err := DefaultTagFormatter(v, tc.cfg)
+		checkFatal(t, err)
+		assert.Equal(t, tc.expected, name)
+	}
+}
+
+func TestTagPattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    bool
+		shouldErr bool
+	}{
+		{
+			name:   "tag matches pattern",
+			prefix: true,
+		},
+		{
+			name:      "misconfigured prefix is rejected",
+			prefix:    false,
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.prefix = tc.prefix
+			r.tagPattern = "v[0-9]*"
+
+			_, err = r.AutoTag()
+			if tc.shouldErr {
+				assert.EqualError(t, err, "tag '1.1.0' does not match tag pattern 'v[0-9]*'")
+				assert.Equal(t, "v1.0.0", runGit(t, r.repo, "tag", "--list"))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "v1.0.0\nv1.1.0", runGit(t, r.repo, "tag", "--list"))
+			}
+		})
+	}
+}
+
+func TestBumpMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    testRepoSetup
+		expected string
+	}{
+		{
+			name: "major bump",
 			setup: testRepoSetup{
-				scheme:                    "autotag",
-				nextCommit:                "#patch bump",
-				initialTag:                "v1.0.0",
-				preReleaseName:            "dev",
-				preReleaseTimestampLayout: "epoch",
+				initialTag: "v1.2.2",
+				nextCommit: "[major] this is a big release",
+			},
+			expected: "Bumping v1.2.2 → v2.0.0 (major)",
+		},
+		{
+			name: "minor bump",
+			setup: testRepoSetup{
+				initialTag: "v1.2.2",
+				nextCommit: "[minor] this is a smaller release",
+			},
+			expected: "Bumping v1.2.2 → v1.3.0 (minor)",
+		},
+		{
+			name: "patch bump",
+			setup: testRepoSetup{
+				initialTag: "v1.2.2",
+				nextCommit: "this is just a basic change",
+			},
+			expected: "Bumping v1.2.2 → v1.2.3 (patch)",
+		},
+		{
+			name: "patch bump with pre-release and build metadata",
+			setup: testRepoSetup{
+				initialTag:     "1.2.2",
+				nextCommit:     "#patch bump",
+				preReleaseName: "dev",
+				buildMetadata:  "g012345678",
+				disablePrefix:  true,
+			},
+			expected: "Bumping 1.2.2 → 1.2.3-dev+g012345678 (patch)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, tc.setup)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			assert.Equal(t, tc.expected, r.BumpMessage())
+		})
+	}
+
+	t.Run("no bump", func(t *testing.T) {
+		v, err := version.NewVersion("1.2.2")
+		checkFatal(t, err)
+
+		r := GitRepo{currentVersion: v, newVersion: v, prefix: true, strategy: SemVerStrategy{}}
+		assert.Equal(t, "No version bump from v1.2.2", r.BumpMessage())
+	})
+}
+
+func TestSignTag(t *testing.T) {
+	key := setupGPG(t)
+
+	tests := []struct {
+		name        string
+		signingKey  string
+		expectedErr string
+	}{
+		{
+			name:       "signing key",
+			signingKey: key,
+		},
+		{
+			name:        "unknown signing key",
+			signingKey:  "missing@example.com",
+			expectedErr: "error signing tag 'v1.1.0', check the signing key and gpg agent are available",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.sign = true
+			r.signingKey = tc.signingKey
+			r.tagMessage = "Release {{.Version}}"
+			_, err = r.AutoTag()
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+
+			runGit(t, r.repo, "verify-tag", "v1.1.0")
+			assert.Equal(t, "Release 1.1.0", runGit(t, r.repo, "tag", "--list", "--format=%(contents:subject)", "v1.1.0"))
+		})
+	}
+}
+
+func TestRequireSignedBaseTag(t *testing.T) {
+	key := setupGPG(t)
+
+	tests := []struct {
+		name      string
+		signed    bool
+		shouldErr bool
+	}{
+		{
+			name:      "signed base tag",
+			signed:    true,
+			shouldErr: false,
+		},
+		{
+			name:      "unsigned base tag",
+			signed:    false,
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v0.0.1", repo)
+			updateReadme(t, repo, "release 1.0.0")
+			if tc.signed {
+				makeSignedTag(repo, "v1.0.0", key)
+			} else {
+				makeTag(repo, "v1.0.0")
+			}
+			updateReadme(t, repo, "#minor next feature")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:             repo.Path(),
+				Branch:               "main",
+				RequireSignedBaseTag: true,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "1.1.0", r.LatestVersion())
+		})
+	}
+}
+
+func TestValidateSemVerBuildMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		meta  string
+		valid bool
+	}{
+		{
+			name:  "valid single-part metadata",
+			meta:  "g123456",
+			valid: true,
+		},
+		{
+			name:  "valid multi-part metadata",
+			meta:  "g123456.20200512",
+			valid: true,
+		},
+		{
+			name:  "invalid characters",
+			meta:  "g123456,foo_bar",
+			valid: false,
+		},
+		{
+			name:  "empty string",
+			meta:  "",
+			valid: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid := validateSemVerBuildMetadata(tc.meta)
+			assert.Equal(t, tc.valid, valid)
+		})
+	}
+}
+
+func TestCreateIntermediateTags(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[patch] a fix")
+	patchID := runGit(t, repo, "rev-parse", "HEAD")
+	updateReadme(t, repo, "another basic change")
+	updateReadme(t, repo, "[minor] new feature")
+	minorID := runGit(t, repo, "rev-parse", "HEAD")
+	updateReadme(t, repo, "[major] breaking change")
+	updateReadme(t, repo, "a final change")
+	headID := runGit(t, repo, "rev-parse", "HEAD")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:               repo.Path(),
+		Branch:                 "main",
+		Prefix:                 true,
+		CreateIntermediateTags: true,
+	})
+	checkFatal(t, err)
+
+	// nothing is tagged until AutoTag is called
+	assert.Equal(t, "v1.0.0", runGit(t, repo, "tag", "--list"))
+
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, patchID, runGit(t, repo, "rev-list", "-n1", "v1.0.1"))
+	assert.Equal(t, minorID, runGit(t, repo, "rev-list", "-n1", "v1.1.0"))
+	assert.Equal(t, headID, runGit(t, repo, "rev-list", "-n1", "v2.0.0"))
+}
+
+func TestCreateIntermediateTagsPreRelease(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[patch] a fix")
+	updateReadme(t, repo, "[minor] new feature")
+
+	_, err = NewRepo(GitRepoConfig{
+		RepoPath:               repo.Path(),
+		Branch:                 "main",
+		Prefix:                 true,
+		PreReleaseName:         "rc",
+		PreReleaseNumber:       true,
+		CreateIntermediateTags: true,
+	})
+	assert.EqualError(t, err, "create intermediate tags is not valid with a pre-release")
+	assert.Equal(t, "v1.0.0", runGit(t, repo, "tag", "--list"))
+}
+
+func TestNormalizeLegacySeparators(t *testing.T) {
+	tests := map[string]string{
+		"v1_2_3":      "v1.2.3",
+		"1-2-3":       "1.2.3",
+		"v1_2":        "v1.2",
+		"v1_2_3-rc1":  "v1.2.3-rc1",
+		"v1.2.3-4":    "v1.2.3-4",
+		"v1.2.3":      "v1.2.3",
+		"release_1_2": "release_1_2",
+	}
+	for tag, expected := range tests {
+		t.Run(tag, func(t *testing.T) {
+			assert.Equal(t, expected, normalizeLegacySeparators(tag))
+		})
+	}
+}
+
+func TestLegacySeparators(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1_2_3", repo)
+	updateReadme(t, repo, "[minor] new feature")
+
+	cfg := GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+		Prefix:   true,
+	}
+	_, err = NewRepo(cfg)
+	assert.Error(t, err)
+
+	cfg.LegacySeparators = true
+	r, err := NewRepo(cfg)
+	checkFatal(t, err)
+	assert.Equal(t, "1.2.3", r.currentVersion.String())
+	assert.Equal(t, "v1_2_3", r.currentTagName)
+	assert.Equal(t, "1.3.0", r.LatestVersion())
+}
+
+func TestTagDateTiebreaker(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{
+			name:   "newer tag sorts first by metadata",
+			first:  "v1.0.0+b",
+			second: "v1.0.0+a",
+		},
+		{
+			name:   "newer tag sorts last by metadata",
+			first:  "v1.0.0+a",
+			second: "v1.0.0+b",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v0.0.1", repo)
+			updateReadme(t, repo, "first")
+			firstID := runGit(t, repo, "rev-parse", "HEAD")
+			updateReadme(t, repo, "second")
+			secondID := runGit(t, repo, "rev-parse", "HEAD")
+
+			// the tag on the second commit is created first, so the tag on the first commit is newer
+			t.Setenv("GIT_COMMITTER_DATE", "2020-01-01T00:00:00Z")
+			runGit(t, repo, "tag", "-a", "-m", tc.second, tc.second, secondID)
+			t.Setenv("GIT_COMMITTER_DATE", "2020-01-02T00:00:00Z")
+			runGit(t, repo, "tag", "-a", "-m", tc.first, tc.first, firstID)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: repo.Path(),
+				Branch:   "main",
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.first, r.currentTagName)
+			assert.Equal(t, firstID, r.currentTag.ID.String())
+		})
+	}
+}
+
+func TestTagNameTiebreaker(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	// lightweight tags on the same commit have the same creation date
+	seedTestRepo(t, "v1.0.0+b", repo)
+	makeTag(repo, "v1.0.0+c")
+	makeTag(repo, "v1.0.0+a")
+	updateReadme(t, repo, "a fix")
+
+	for i := 0; i < 20; i++ {
+		r, err := NewRepo(GitRepoConfig{
+			RepoPath: repo.Path(),
+			Branch:   "main",
+		})
+		checkFatal(t, err)
+		assert.Equal(t, "v1.0.0+c", r.currentTagName)
+
+		var names []string
+		for _, tag := range r.tags {
+			names = append(names, tag.Name)
+		}
+		assert.Equal(t, []string{"v1.0.0+c", "v1.0.0+b", "v1.0.0+a"}, names)
+	}
+}
+
+func TestGoModuleVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		compat   bool
+		expected string
+	}{
+		{
+			name:     "v1 unchanged",
+			version:  "1.2.3",
+			compat:   true,
+			expected: "v1.2.3",
+		},
+		{
+			name:     "v2 incompatible",
+			version:  "2.0.0",
+			compat:   true,
+			expected: "v2.0.0+incompatible",
+		},
+		{
+			name:     "v3 pre-release incompatible",
+			version:  "3.1.0-rc.1",
+			compat:   true,
+			expected: "v3.1.0-rc.1+incompatible",
+		},
+		{
+			name:     "build metadata dropped",
+			version:  "2.0.0+build.5",
+			compat:   true,
+			expected: "v2.0.0+incompatible",
+		},
+		{
+			name:     "v2 without compat",
+			version:  "2.0.0",
+			expected: "v2.0.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := GitRepo{
+				newVersion:     version.Must(version.NewVersion(tc.version)),
+				goModuleCompat: tc.compat,
+			}
+			assert.Equal(t, tc.expected, r.GoModuleVersion())
+		})
+	}
+}
+
+func TestMarketingVersion(t *testing.T) {
+	r := GitRepo{newVersion: version.Must(version.NewVersion("1.2.3-rc.1+build.5"))}
+	assert.Equal(t, "1", r.MarketingVersion(1))
+	assert.Equal(t, "1.2", r.MarketingVersion(2))
+	assert.Equal(t, "1.2.3", r.MarketingVersion(3))
+	assert.Equal(t, "1", r.MarketingVersion(0))
+	assert.Equal(t, "1.2.3", r.MarketingVersion(4))
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		approve  bool
+		err      error
+		expected string
+	}{
+		{
+			name:     "approved",
+			approve:  true,
+			expected: "v1.0.0\nv1.1.0",
+		},
+		{
+			name:     "declined",
+			approve:  false,
+			err:      ErrNotConfirmed,
+			expected: "v1.0.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			var asked string
+			r.confirm = func(tag string) (bool, error) {
+				asked = tag
+				return tc.approve, nil
+			}
+
+			_, err = r.AutoTag()
+			if tc.err != nil {
+				assert.IsError(t, err, tc.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, "v1.1.0", asked)
+			assert.Equal(t, tc.expected, runGit(t, r.repo, "tag", "--list"))
+		})
+	}
+}
+
+func TestStrictPreReleaseOrdering(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      GitRepoConfig
+		expected string
+	}{
+		{
+			name: "numbered pre-release sorts in order",
+			cfg: GitRepoConfig{
+				PreReleaseName:   "rc",
+				PreReleaseNumber: true,
 			},
-			expectedTag: fmt.Sprintf("v1.0.1-dev.%d", timeNow().UTC().Unix()),
 		},
 		{
-			name: "ignore non-conforming tags",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "#patch bump",
+			name: "hyphenated pre-release name sorts in order",
+			cfg: GitRepoConfig{
+				PreReleaseName:   "rc-1",
+				PreReleaseNumber: true,
+			},
+		},
+		{
+			name: "dotted pre-release name repeats the same version",
+			cfg: GitRepoConfig{
+				PreReleaseName:   "rc.1",
+				PreReleaseNumber: true,
+			},
+			expected: "pre-release name 'rc.1' does not sort in order; '1.0.0-rc.1.1' is not after '1.0.0-rc.1.1'",
+		},
+		{
+			name: "not checked without a pre-release number",
+			cfg: GitRepoConfig{
+				PreReleaseName: "rc.1",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Branch = "main"
+			tc.cfg.StrictPreReleaseOrdering = true
+			err := validateConfig(tc.cfg)
+			if tc.expected != "" {
+				assert.EqualError(t, err, tc.expected)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStrictPreReleaseOrderingDottedName(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "a fix",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	cfg := GitRepoConfig{
+		RepoPath:         repoRoot(r.repo),
+		Branch:           "main",
+		Prefix:           true,
+		PreReleaseName:   "rc.1",
+		PreReleaseNumber: true,
+	}
+
+	// without the check every run calculates the same pre-release, the second one cannot tag it
+	for run := 1; run <= 2; run++ {
+		updateReadme(t, r.repo, fmt.Sprintf("change %d", run))
+		next, err := NewRepo(cfg)
+		checkFatal(t, err)
+		assert.Equal(t, "1.0.1-rc.1.1", next.LatestVersion())
+		_, err = next.AutoTag()
+		if run == 2 {
+			assert.Error(t, err)
+		} else {
+			checkFatal(t, err)
+		}
+	}
+
+	cfg.StrictPreReleaseOrdering = true
+	_, err = NewRepo(cfg)
+	assert.EqualError(t, err, "pre-release name 'rc.1' does not sort in order; '1.0.0-rc.1.1' is not after '1.0.0-rc.1.1'")
+}
+
+func TestFloatingAliases(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.2.3",
+		nextCommit: "[patch] fix",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	r.floatingAliases = true
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-list", "-n1", "v1"))
+	assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-list", "-n1", "v1.2"))
+
+	// the aliases are ignored when looking for the latest version and move with the next release
+	updateReadme(t, r.repo, "[minor] new feature")
+	next, err := NewRepo(GitRepoConfig{
+		RepoPath:        repoRoot(r.repo),
+		Branch:          "main",
+		Prefix:          true,
+		FloatingAliases: true,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "v1.2.4", next.currentTagName)
+	_, err = next.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, next.branchID, runGit(t, r.repo, "rev-list", "-n1", "v1"))
+	assert.Equal(t, next.branchID, runGit(t, r.repo, "rev-list", "-n1", "v1.3"))
+	assert.Equal(t, r.branchID, runGit(t, r.repo, "rev-list", "-n1", "v1.2"))
+}
+
+func TestFloatingAliasesAnnotatedTag(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.2.3",
+		nextCommit: "[patch] fix",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	runGit(t, r.repo, "tag", "-a", "-m", "hand made release", "v1.2", "v1.2.3")
+	baseID := runGit(t, r.repo, "rev-list", "-n1", "v1.2.3")
+
+	r.floatingAliases = true
+	_, err = r.AutoTag()
+	assert.EqualError(t, err, "tag 'v1.2' is an annotated tag, not a floating alias")
+	assert.Equal(t, baseID, runGit(t, r.repo, "rev-list", "-n1", "v1.2"))
+	assert.Equal(t, "v1.2\nv1.2.3\nv1.2.4", runGit(t, r.repo, "tag", "--list"))
+}
+
+func TestFloatingAliasesChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		tagPattern  string
+		branch      string
+		expectedErr string
+	}{
+		{
+			name:        "tag pattern",
+			tagPattern:  "v*.*.*",
+			expectedErr: "tag 'v1' does not match tag pattern 'v*.*.*'",
+		},
+		{
+			name:        "ref collision",
+			branch:      "v1.2",
+			expectedErr: "tag 'v1.2' has the same name as a branch",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.2.3",
+				nextCommit: "[patch] fix",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			if tc.branch != "" {
+				runGit(t, r.repo, "branch", tc.branch)
+				r.checkRefCollision = true
+			}
+			r.tagPattern = tc.tagPattern
+			r.floatingAliases = true
+			_, err = r.AutoTag()
+			assert.EqualError(t, err, tc.expectedErr)
+
+			// nothing is tagged when an alias is rejected
+			assert.Equal(t, "v1.2.3", runGit(t, r.repo, "tag", "--list"))
+		})
+	}
+}
+
+func TestBumpFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		absent    bool
+		expected  string
+		tagged    bool
+		shouldErr bool
+	}{
+		{
+			name:     "major",
+			content:  "major\n",
+			expected: "2.0.0",
+			tagged:   true,
+		},
+		{
+			name:     "minor",
+			content:  "minor",
+			expected: "1.1.0",
+			tagged:   true,
+		},
+		{
+			name:     "patch",
+			content:  "patch",
+			expected: "1.0.1",
+			tagged:   true,
+		},
+		{
+			name:     "none",
+			content:  "none",
+			expected: "1.0.0",
+		},
+		{
+			name:     "absent uses commits",
+			absent:   true,
+			expected: "2.0.0",
+			tagged:   true,
+		},
+		{
+			name:      "invalid",
+			content:   "huge",
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "[major] breaking change")
+
+			bumpFile := filepath.Join(repoRoot(repo), ".autotag-bump")
+			if !tc.absent {
+				checkFatal(t, os.WriteFile(bumpFile, []byte(tc.content), 0o644))
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:       repo.Path(),
+				Branch:         "main",
+				Prefix:         true,
+				RemoveBumpFile: true,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+
+			_, err = r.AutoTag()
+			checkFatal(t, err)
+			if tc.tagged {
+				assert.Equal(t, "v1.0.0\nv"+tc.expected, runGit(t, repo, "tag", "--list"))
+			} else {
+				assert.Equal(t, "v1.0.0", runGit(t, repo, "tag", "--list"))
+			}
+
+			_, err = os.Stat(bumpFile)
+			assert.True(t, os.IsNotExist(err), "bump file should be removed")
+		})
+	}
+}
+
+func TestBumpFileDryRun(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[major] breaking change")
+
+	bumpFile := filepath.Join(repoRoot(repo), ".autotag-bump")
+	checkFatal(t, os.WriteFile(bumpFile, []byte("none"), 0o644))
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:       repo.Path(),
+		Branch:         "main",
+		DryRun:         true,
+		RemoveBumpFile: true,
+	})
+	checkFatal(t, err)
+
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+
+	// nothing is written in a dry run, the bump file is kept for the real run
+	_, err = os.Stat(bumpFile)
+	assert.NoError(t, err)
+}
+
+func TestSchemeConflicts(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "feat: [major] thing",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	conflictID := r.branchID
+	updateReadme(t, r.repo, "feat: [minor] agreed feature")
+	updateReadme(t, r.repo, "fix: a fix")
+	updateReadme(t, r.repo, "[major] autotag only")
+	updateReadme(t, r.repo, "fix!: #patch breaking fix")
+	breakingID := runGit(t, r.repo, "rev-parse", "HEAD")
+
+	next, err := NewRepo(GitRepoConfig{
+		RepoPath: repoRoot(r.repo),
+		Branch:   "main",
+	})
+	checkFatal(t, err)
+
+	conflicts, err := next.SchemeConflicts()
+	checkFatal(t, err)
+	assert.Equal(t, []string{
+		conflictID + ": autotag major, conventional minor: feat: [major] thing",
+		breakingID + ": autotag patch, conventional major: fix!: #patch breaking fix",
+	}, conflicts)
+}
+
+func TestMixedVersionPrefixes(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "second release")
+	makeTag(repo, "V1.1.0")
+	updateReadme(t, repo, "third release")
+	makeTag(repo, "1.2.0")
+	updateReadme(t, repo, "a change")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+		Prefix:   true,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, 3, r.Stats().TagsParsed)
+	assert.Equal(t, "1.2.0", r.currentVersion.String())
+	assert.Equal(t, "1.2.0", r.currentTagName)
+	assert.Equal(t, "1.2.1", r.LatestVersion())
+}
+
+func TestLinkedWorktree(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+
+	wt := filepath.Join(t.TempDir(), "worktree")
+	runGit(t, repo, "worktree", "add", "-b", "feature", wt)
+	checkFatal(t, os.WriteFile(filepath.Join(wt, "README"), []byte("feature"), 0o644))
+	wtRepo, err := git.Open(wt)
+	checkFatal(t, err)
+	makeCommit(wtRepo, "[minor] new feature")
+
+	gitDir, err := generateGitDirPath(wt)
+	checkFatal(t, err)
+	assert.Equal(t, filepath.Join(repoRoot(repo), ".git", "worktrees", "worktree"), filepath.Clean(gitDir))
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: wt,
+		Branch:   "feature",
+		Prefix:   true,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, runGit(t, wtRepo, "rev-parse", "HEAD"), runGit(t, repo, "rev-list", "-n1", "v1.1.0"))
+}
+
+func TestReleaseContributors(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	// commits before the current tag are not part of the release
+	t.Setenv("GIT_AUTHOR_NAME", "Earlier Author")
+	seedTestRepo(t, "v1.0.0", repo)
+
+	commits := []struct {
+		author string
+		msg    string
+	}{
+		{author: "Alice", msg: "first"},
+		{author: "Bob", msg: "second"},
+		{author: "Alice", msg: "third"},
+		{author: "Carol", msg: "fourth"},
+		{author: "Alice", msg: "fifth"},
+	}
+	for _, c := range commits {
+		t.Setenv("GIT_AUTHOR_NAME", c.author)
+		updateReadme(t, repo, c.msg)
+	}
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+	})
+	checkFatal(t, err)
+
+	contributors, err := r.ReleaseContributors()
+	checkFatal(t, err)
+	assert.Equal(t, map[string]int{"Alice": 3, "Bob": 1, "Carol": 1}, contributors)
+}
+
+func TestTagDateTiebreakerDateSource(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v0.0.1", repo)
+
+	// lightweight tags use the date of the commit; the cherry-picked commit has a late author date
+	t.Setenv("GIT_AUTHOR_DATE", "2020-03-01T00:00:00Z")
+	t.Setenv("GIT_COMMITTER_DATE", "2020-01-01T00:00:00Z")
+	updateReadme(t, repo, "cherry-picked")
+	makeTag(repo, "v1.0.0+a")
+	t.Setenv("GIT_AUTHOR_DATE", "2020-02-01T00:00:00Z")
+	t.Setenv("GIT_COMMITTER_DATE", "2020-02-01T00:00:00Z")
+	updateReadme(t, repo, "rebuilt")
+	makeTag(repo, "v1.0.0+b")
+
+	tests := []struct {
+		dateSource string
+		expected   string
+	}{
+		{dateSource: "author", expected: "v1.0.0+a"},
+		{dateSource: "committer", expected: "v1.0.0+b"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dateSource, func(t *testing.T) {
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:   repo.Path(),
+				Branch:     "main",
+				DateSource: tc.dateSource,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.currentTagName)
+		})
+	}
+}
+
+func TestNoReleaseMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		commits  []string
+		err      error
+		expected string
+	}{
+		{
+			name:    "head commit blocks the release",
+			commits: []string{"[major] breaking change", "wip [no-release]"},
+			err:     ErrReleaseBlocked,
+		},
+		{
+			name:    "hashtag marker",
+			commits: []string{"a fix #no-release"},
+			err:     ErrReleaseBlocked,
+		},
+		{
+			name:     "earlier commit does not block",
+			commits:  []string{"wip [no-release]", "[minor] done"},
+			expected: "1.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			for _, c := range tc.commits {
+				updateReadme(t, repo, c)
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: repo.Path(),
+				Branch:   "main",
+			})
+			if tc.err != nil {
+				assert.IsError(t, err, tc.err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestMinReleaseInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  time.Duration
+		annotated bool
+		err       error
+		expected  string
+	}{
+		{
+			name:     "disabled",
+			expected: "1.0.1",
+		},
+		{
+			name:     "interval elapsed",
+			interval: 6 * time.Hour,
+			expected: "1.0.1",
+		},
+		{
+			name:     "interval not elapsed",
+			interval: 24 * time.Hour,
+			err:      ErrTooSoon,
+		},
+		{
+			name:      "annotated tag date",
+			interval:  24 * time.Hour,
+			annotated: true,
+			err:       ErrTooSoon,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// the latest release is 12h before the fixed timeNow
+			t.Setenv("GIT_AUTHOR_DATE", "2018-12-31T12:00:00Z")
+			t.Setenv("GIT_COMMITTER_DATE", "2018-12-31T12:00:00Z")
+
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v0.9.0", repo)
+			updateReadme(t, repo, "release")
+			if tc.annotated {
+				runGit(t, repo, "tag", "-a", "v1.0.0", "-m", "v1.0.0")
+			} else {
+				makeTag(repo, "v1.0.0")
+			}
+			updateReadme(t, repo, "a fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:           repo.Path(),
+				Branch:             "main",
+				MinReleaseInterval: tc.interval,
+			})
+			if tc.err != nil {
+				assert.IsError(t, err, tc.err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestBumpFromTagAnnotation(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		message   string
+		commit    string
+		expected  string
+		shouldErr bool
+	}{
+		{
+			name:     "disabled",
+			message:  "v1.0.0\n\nNext-Bump: minor",
+			commit:   "a fix",
+			expected: "1.0.1",
+		},
+		{
+			name:     "next bump from annotation",
+			enabled:  true,
+			message:  "v1.0.0\n\nNext-Bump: minor",
+			commit:   "a fix",
+			expected: "1.1.0",
+		},
+		{
+			name:     "commit directive wins",
+			enabled:  true,
+			message:  "v1.0.0\n\nNext-Bump: minor",
+			commit:   "[major] breaking change",
+			expected: "2.0.0",
+		},
+		{
+			name:     "lightweight tag",
+			enabled:  true,
+			commit:   "a fix",
+			expected: "1.0.1",
+		},
+		{
+			name:      "invalid next bump",
+			enabled:   true,
+			message:   "v1.0.0\n\nNext-Bump: huge",
+			commit:    "a fix",
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v0.9.0", repo)
+			updateReadme(t, repo, "release")
+			if tc.message != "" {
+				runGit(t, repo, "tag", "-a", "v1.0.0", "-m", tc.message)
+			} else {
+				makeTag(repo, "v1.0.0")
+			}
+			updateReadme(t, repo, tc.commit)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:              repo.Path(),
+				Branch:                "main",
+				BumpFromTagAnnotation: tc.enabled,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestRejectFutureCommits(t *testing.T) {
+	tests := []struct {
+		name      string
+		reject    bool
+		date      string
+		shouldErr bool
+	}{
+		{
+			name: "disabled",
+			date: "2019-01-02T00:00:00Z",
+		},
+		{
+			name:      "future commit",
+			reject:    true,
+			date:      "2019-01-02T00:00:00Z",
+			shouldErr: true,
+		},
+		{
+			name:   "within tolerance",
+			reject: true,
+			date:   "2019-01-01T00:02:00Z",
+		},
+		{
+			name:   "past commit",
+			reject: true,
+			date:   "2018-12-31T00:00:00Z",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GIT_AUTHOR_DATE", "2018-12-30T00:00:00Z")
+			t.Setenv("GIT_COMMITTER_DATE", "2018-12-30T00:00:00Z")
+
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			t.Setenv("GIT_AUTHOR_DATE", tc.date)
+			updateReadme(t, repo, "a fix")
+			t.Setenv("GIT_AUTHOR_DATE", "2018-12-31T00:00:00Z")
+			updateReadme(t, repo, "another fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:            repo.Path(),
+				Branch:              "main",
+				RejectFutureCommits: tc.reject,
+			})
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, "1.0.1", r.LatestVersion())
+		})
+	}
+}
+
+func TestSkipWhenNothingToTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		strictMatch bool
+		skip        bool
+		err         error
+		expected    string
+	}{
+		{
+			name:        "strict match errors",
+			strictMatch: true,
+			err:         ErrNothingToTag,
+		},
+		{
+			name:        "strict match skips",
+			strictMatch: true,
+			skip:        true,
+			expected:    "1.0.0",
+		},
+		{
+			name:     "skips without strict match",
+			skip:     true,
+			expected: "1.0.0",
+		},
+		{
+			name:     "patch bump without strict match",
+			expected: "1.0.1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:             repo.Path(),
+				Branch:               "main",
+				Prefix:               true,
+				StrictMatch:          tc.strictMatch,
+				SkipWhenNothingToTag: tc.skip,
+			})
+			if tc.err != nil {
+				assert.IsError(t, err, tc.err)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+
+			_, err = r.AutoTag()
+			checkFatal(t, err)
+			if tc.skip {
+				assert.Equal(t, "v1.0.0", runGit(t, repo, "tag", "--list"))
+			}
+		})
+	}
+}
+
+func TestLockMajor(t *testing.T) {
+	tests := []struct {
+		name      string
+		initial   string
+		commit    string
+		lockMajor bool
+		expected  string
+	}{
+		{
+			name:      "major on 0.x becomes minor",
+			initial:   "v0.3.2",
+			commit:    "[major] breaking change",
+			lockMajor: true,
+			expected:  "0.4.0",
+		},
+		{
+			name:     "major on 0.x without lock",
+			initial:  "v0.3.2",
+			commit:   "[major] breaking change",
+			expected: "1.0.0",
+		},
+		{
+			name:      "minor unaffected",
+			initial:   "v0.3.2",
+			commit:    "[minor] new feature",
+			lockMajor: true,
+			expected:  "0.4.0",
+		},
+		{
+			name:      "patch unaffected",
+			initial:   "v0.3.2",
+			commit:    "a fix",
+			lockMajor: true,
+			expected:  "0.3.3",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, tc.initial, repo)
+			updateReadme(t, repo, tc.commit)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:  repo.Path(),
+				Branch:    "main",
+				LockMajor: tc.lockMajor,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestCheckRefCollision(t *testing.T) {
+	tests := []struct {
+		name      string
+		check     bool
+		shouldErr bool
+	}{
+		{
+			name:      "branch named like the tag is rejected",
+			check:     true,
+			shouldErr: true,
+		},
+		{
+			name:  "not checked by default",
+			check: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			runGit(t, r.repo, "branch", "v1.1.0")
+			r.checkRefCollision = tc.check
+
+			_, err = r.AutoTag()
+			if tc.shouldErr {
+				assert.EqualError(t, err, "tag 'v1.1.0' has the same name as a branch")
+				assert.Equal(t, "v1.0.0", runGit(t, r.repo, "tag", "--list"))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSchemeEnvVar(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		scheme    string
+		expected  string
+		shouldErr bool
+	}{
+		{
+			name:     "conventional from env",
+			env:      "conventional",
+			expected: "1.1.0",
+		},
+		{
+			name:     "autotag from env",
+			env:      "autotag",
+			expected: "1.0.1",
+		},
+		{
+			name:     "config takes precedence",
+			env:      "conventional",
+			scheme:   "autotag",
+			expected: "1.0.1",
+		},
+		{
+			name:     "default without env",
+			expected: "1.0.1",
+		},
+		{
+			name:      "invalid env",
+			env:       "semantic",
+			shouldErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AUTOTAG_SCHEME", tc.env)
+
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "feat: new feature")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: repo.Path(),
+				Branch:   "main",
+				Scheme:   tc.scheme,
+			})
+			if tc.shouldErr {
+				assert.EqualError(t, err, "AUTOTAG_SCHEME 'semantic' is not valid; must be (autotag|conventional)")
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestBaseTagLabel(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v0.9.0", repo)
+	updateReadme(t, repo, "release base")
+	runGit(t, repo, "tag", "-a", "-m", "release 1.0.0\n\nAutotag-Base: true", "v1.0.0")
+	updateReadme(t, repo, "higher release")
+	runGit(t, repo, "tag", "-a", "-m", "release 1.1.0", "v1.1.0")
+	// a lightweight tag has no message of its own, the commit message doesn't count
+	updateReadme(t, repo, "Autotag-Base: true")
+	makeTag(repo, "v1.2.0")
+	updateReadme(t, repo, "a fix")
+
+	tests := []struct {
+		name     string
+		label    string
+		expected string
+	}{
+		{
+			name:     "lower labeled tag is the base",
+			label:    "Autotag-Base: true",
+			expected: "v1.0.0",
+		},
+		{
+			name:     "latest stable tag without a label",
+			expected: "v1.2.0",
+		},
+		{
+			name:     "latest stable tag when no tag is labeled",
+			label:    "Autotag-Base: yes",
+			expected: "v1.2.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:     repo.Path(),
+				Branch:       "main",
+				Prefix:       true,
+				BaseTagLabel: tc.label,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.currentTagName)
+			assert.Equal(t, "v1.2.0", "v"+r.latestTagVersion.String())
+		})
+	}
+}
+
+func TestRequireSignedSuperseded(t *testing.T) {
+	key := setupGPG(t)
+
+	tests := []struct {
+		name            string
+		signedRC        bool
+		superseded      bool
+		expected        string
+		expectedWarning string
+	}{
+		{
+			name:       "signed superseded tags",
+			signedRC:   true,
+			superseded: true,
+		},
+		{
+			name:       "unsigned superseded tag",
+			superseded: true,
+			expected:   "tags superseded by base tag 'v1.0.0' could not be verified: v1.1.0-rc.2",
+		},
+		{
+			name:            "unsigned superseded tag warning",
+			expectedWarning: "tags superseded by base tag 'v1.0.0' could not be verified: v1.1.0-rc.2",
+		},
+		{
+			name:     "signed superseded tags without warning",
+			signedRC: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v0.0.1", repo)
+			updateReadme(t, repo, "release 1.0.0")
+			makeSignedTag(repo, "v1.0.0", key)
+			updateReadme(t, repo, "release candidate 1")
+			makeSignedTag(repo, "v1.1.0-rc.1", key)
+			updateReadme(t, repo, "release candidate 2")
+			if tc.signedRC {
+				makeSignedTag(repo, "v1.1.0-rc.2", key)
+			} else {
+				makeTag(repo, "v1.1.0-rc.2")
+			}
+			updateReadme(t, repo, "#minor next feature")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:                repo.Path(),
+				Branch:                  "main",
+				RequireSignedBaseTag:    true,
+				RequireSignedSuperseded: tc.superseded,
+			})
+			if tc.expected != "" {
+				assert.EqualError(t, err, tc.expected)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "1.1.0", r.LatestVersion())
+			if tc.expectedWarning != "" {
+				assert.Equal(t, []string{tc.expectedWarning}, r.Warnings())
+			} else {
+				assert.Zero(t, r.Warnings())
+			}
+		})
+	}
+}
+
+func TestMirrorClone(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	runGit(t, repo, "checkout", "-q", "-b", "feature")
+	updateReadme(t, repo, "[minor] new feature")
+	featureID := runGit(t, repo, "rev-parse", "HEAD")
+	runGit(t, repo, "checkout", "-q", "main")
+	updateReadme(t, repo, "a fix")
+
+	mirror := filepath.Join(t.TempDir(), "mirror.git")
+	runGit(t, repo, "clone", "-q", "--mirror", repoRoot(repo), mirror)
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: mirror,
+		Branch:   "feature",
+		Prefix:   true,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, featureID, r.branchID)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+	out, err := exec.Command("git", "--git-dir", mirror, "rev-list", "-n1", "v1.1.0").CombinedOutput()
+	checkFatal(t, err)
+	assert.Equal(t, featureID, string(bytes.TrimSpace(out)))
+}
+
+func TestRemoteTrackingBranch(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[minor] new feature")
+
+	// a clone with a detached HEAD and no local branches, as left by some CI checkouts
+	clone := filepath.Join(t.TempDir(), "clone")
+	runGit(t, repo, "clone", "-q", repoRoot(repo), clone)
+	cloneRepo, err := git.Open(clone)
+	checkFatal(t, err)
+	runGit(t, cloneRepo, "checkout", "-q", "--detach")
+	runGit(t, cloneRepo, "branch", "-D", "main")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: clone,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "main", r.branch)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+}
+
+func TestRemoteQualifiedBranch(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[minor] new feature")
+
+	// the local main is behind origin/main
+	clone := filepath.Join(t.TempDir(), "clone")
+	runGit(t, repo, "clone", "-q", repoRoot(repo), clone)
+	cloneRepo, err := git.Open(clone)
+	checkFatal(t, err)
+	runGit(t, cloneRepo, "reset", "-q", "--hard", "v1.0.0")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: clone,
+		Branch:   "origin/main",
+	})
+	checkFatal(t, err)
+	assert.Equal(t, runGit(t, cloneRepo, "rev-parse", "origin/main"), r.branchID)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+
+	_, err = NewRepo(GitRepoConfig{
+		RepoPath: clone,
+		Branch:   "upstream/main",
+	})
+	assert.Error(t, err)
+}
+
+func TestDockerTag(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		separator string
+		expected  string
+	}{
+		{
+			name:     "plain version",
+			version:  "1.2.3",
+			expected: "1.2.3",
+		},
+		{
+			name:     "pre-release",
+			version:  "1.2.3-RC.1",
+			expected: "1.2.3-rc.1",
+		},
+		{
+			name:     "build metadata",
+			version:  "1.2.3+Build.5",
+			expected: "1.2.3_build.5",
+		},
+		{
+			name:      "pre-release and build metadata with separator",
+			version:   "1.2.3-rc.1+g1a2b3c",
+			separator: "-",
+			expected:  "1.2.3-rc.1-g1a2b3c",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sep := tc.separator
+			if sep == "" {
+				sep = "_"
+			}
+			r := GitRepo{
+				newVersion:         version.Must(version.NewVersion(tc.version)),
+				strategy:           SemVerStrategy{},
+				dockerTagSeparator: sep,
+			}
+			assert.Equal(t, tc.expected, r.DockerTag())
+		})
+	}
+}
+
+func TestMaxPreReleaseNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		latestRC string
+		max      int
+		expected string
+	}{
+		{
+			name:     "below the maximum continues",
+			latestRC: "v1.0.1-rc.2",
+			max:      3,
+			expected: "1.0.1-rc.3",
+		},
+		{
+			name:     "at the maximum promotes",
+			latestRC: "v1.0.1-rc.3",
+			max:      3,
+			expected: "1.0.1",
+		},
+		{
+			name:     "no maximum",
+			latestRC: "v1.0.1-rc.3",
+			expected: "1.0.1-rc.4",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "a fix")
+			makeTag(repo, tc.latestRC)
+			updateReadme(t, repo, "another fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:            repo.Path(),
+				Branch:              "main",
+				PreReleaseName:      "rc",
+				PreReleaseNumber:    true,
+				MaxPreReleaseNumber: tc.max,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestGitConfigDefaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		gitConfig   map[string]string
+		seedTag     string
+		cfg         GitRepoConfig
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:      "scheme",
+			gitConfig: map[string]string{"autotag.scheme": "conventional"},
+			expected:  "1.1.0",
+		},
+		{
+			name:      "scheme set in config takes precedence",
+			gitConfig: map[string]string{"autotag.scheme": "conventional"},
+			cfg:       GitRepoConfig{Scheme: "autotag"},
+			expected:  "1.0.1",
+		},
+		{
+			name:      "prefix",
+			gitConfig: map[string]string{"autotag.prefix": "release-"},
+			seedTag:   "release-1.0.0",
+			expected:  "1.0.1",
+		},
+		{
+			name:      "pre-release name",
+			gitConfig: map[string]string{"autotag.preReleaseName": "rc"},
+			expected:  "1.0.1-rc",
+		},
+		{
+			name:      "pre-release name set in config takes precedence",
+			gitConfig: map[string]string{"autotag.preReleaseName": "rc"},
+			cfg:       GitRepoConfig{PreReleaseName: "beta"},
+			expected:  "1.0.1-beta",
+		},
+		{
+			name:        "invalid scheme",
+			gitConfig:   map[string]string{"autotag.scheme": "semantic"},
+			expectedErr: "git config autotag.scheme 'semantic' is not valid; must be (autotag|conventional)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AUTOTAG_SCHEME", "")
+
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTag := tc.seedTag
+			if seedTag == "" {
+				seedTag = "v1.0.0"
+			}
+			seedTestRepo(t, seedTag, repo)
+			updateReadme(t, repo, "feat: new feature")
+
+			for key, value := range tc.gitConfig {
+				runGit(t, repo, "config", key, value)
+			}
+
+			cfg := tc.cfg
+			cfg.RepoPath = repo.Path()
+			cfg.Branch = "main"
+			cfg.Prefix = true
+			r, err := NewRepo(cfg)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestReleaseTrain(t *testing.T) {
+	tests := []struct {
+		name        string
+		train       string
+		tag         string
+		commit      string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "patch within the train",
+			train:    "isoweek",
+			tag:      "v1.201901.0",
+			commit:   "a fix",
+			expected: "1.201901.1",
+		},
+		{
+			name:     "minor bump within the train",
+			train:    "isoweek",
+			tag:      "v1.201901.1",
+			commit:   "[minor] a feature",
+			expected: "1.201901.2",
+		},
+		{
+			name:     "new train resets the patch",
+			train:    "isoweek",
+			tag:      "v1.201852.3",
+			commit:   "a fix",
+			expected: "1.201901.0",
+		},
+		{
+			name:     "major bump",
+			train:    "isoweek",
+			tag:      "v1.201901.1",
+			commit:   "[major] breaking",
+			expected: "2.201901.0",
+		},
+		{
+			name:     "month",
+			train:    "month",
+			tag:      "v1.201812.4",
+			commit:   "a fix",
+			expected: "1.201901.0",
+		},
+		{
+			name:        "train behind the current version",
+			train:       "isoweek",
+			tag:         "v1.201902.0",
+			commit:      "a fix",
+			expectedErr: "release train '201901' is behind the current version '1.201902.0'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, tc.tag, repo)
+			updateReadme(t, repo, tc.commit)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:     repo.Path(),
+				Branch:       "main",
+				ReleaseTrain: tc.train,
+			})
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestTagMessageIncludePrevious(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.2.2",
+		nextCommit: "a fix",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	r.includePrevious = true
+	_, err = r.AutoTag()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "tag", runGit(t, r.repo, "cat-file", "-t", "v1.2.3"))
+	assert.Equal(t, "v1.2.3\n\nPrevious-Version: v1.2.2", runGit(t, r.repo, "tag", "--list", "--format=%(contents)", "v1.2.3"))
+}
+
+func TestAnnotatedTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		previous bool
+		expected string
+	}{
+		{
+			name:     "default message",
+			expected: "1.1.0",
+		},
+		{
+			name:     "template",
+			message:  "Release {{.Version}} from {{.PreviousVersion}}",
+			expected: "Release 1.1.0 from 1.0.0",
+		},
+		{
+			name:     "with previous version",
+			message:  "Release {{.Version}}",
+			previous: true,
+			expected: "Release 1.1.0\n\nPrevious-Version: v1.0.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
 				initialTag: "v1.0.0",
-				extraTags:  []string{"foo", ""},
+				nextCommit: "[minor] new feature",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.annotated = true
+			r.tagMessage = tc.message
+			r.includePrevious = tc.previous
+			_, err = r.AutoTag()
+			checkFatal(t, err)
+
+			assert.Equal(t, "tag", runGit(t, r.repo, "cat-file", "-t", "v1.1.0"))
+			assert.Equal(t, tc.expected, runGit(t, r.repo, "tag", "--list", "--format=%(contents)", "v1.1.0"))
+		})
+	}
+}
+
+func TestTagMessageIncludeIssues(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous bool
+		commits  []string
+		expected string
+	}{
+		{
+			name: "duplicates are listed once",
+			commits: []string{
+				"fix crash\n\nFixes #12",
+				"add thing\n\nCloses #34, #12\nResolves: #56 and #7",
+				"fix typo closes #34",
 			},
-			expectedTag: "v1.0.1",
+			expected: "v1.2.3\n\nCloses: #12, #34, #56, #7",
 		},
 		{
-			name: "test with pre-relase tag",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				nextCommit: "#patch bump",
+			name:     "with previous version",
+			previous: true,
+			commits:  []string{"fix crash\n\nFixes #12"},
+			expected: "v1.2.3\n\nPrevious-Version: v1.2.2\nCloses: #12",
+		},
+		{
+			name:    "references are not closing",
+			commits: []string{"fix crash, see #12", "prefixes #34"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.2.2",
+				commitList: tc.commits,
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.includePrevious = tc.previous
+			r.includeIssues = true
+			_, err = r.AutoTag()
+			assert.NoError(t, err)
+
+			// without issues or the previous version the tag is lightweight
+			if tc.expected == "" {
+				assert.Equal(t, "commit", runGit(t, r.repo, "cat-file", "-t", "v1.2.3"))
+				return
+			}
+			assert.Equal(t, tc.expected, runGit(t, r.repo, "tag", "--list", "--format=%(contents)", "v1.2.3"))
+		})
+	}
+}
+
+func TestRepoPathErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, err := NewRepo(GitRepoConfig{RepoPath: missing, Branch: "main"})
+	assert.EqualError(t, err, fmt.Sprintf("repository path '%s' does not exist: stat %s: no such file or directory", missing, missing))
+	assert.IsError(t, err, fs.ErrNotExist)
+
+	notGit := t.TempDir()
+	_, err = NewRepo(GitRepoConfig{RepoPath: notGit, Branch: "main"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("repository path '%s' is not a git repository", notGit))
+}
+
+// makeManyTags creates the tags in one update-ref call, all pointing at the head commit
+func makeManyTags(tb testing.TB, repo *git.Repository, tags []string) {
+	head := runGit(tb, repo, "rev-parse", "HEAD")
+	var refs bytes.Buffer
+	for _, tag := range tags {
+		fmt.Fprintf(&refs, "create refs/tags/%s %s\n", tag, head)
+	}
+	cmd := exec.Command("git", "update-ref", "--stdin")
+	cmd.Dir = repoRoot(repo)
+	cmd.Stdin = &refs
+	if out, err := cmd.CombinedOutput(); err != nil {
+		tb.Fatalf("creating tags failed: %s: %s", err, out)
+	}
+}
+
+func TestParseTagsManyTags(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	var tags []string
+	for i := 1; i <= 500; i++ {
+		// stale pre-releases below the base must not be used as the pre-release counter
+		tags = append(tags, fmt.Sprintf("v1.0.%d-rc.9", i), fmt.Sprintf("v1.0.%d", i))
+	}
+	makeManyTags(t, repo, tags)
+	updateReadme(t, repo, "a fix")
+	makeManyTags(t, repo, []string{"v1.0.501-rc.1", "v1.0.501-rc.2", "v1.0.501-beta.5", "not-a-version"})
+	updateReadme(t, repo, "another fix")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:         repo.Path(),
+		Branch:           "main",
+		PreReleaseName:   "rc",
+		PreReleaseNumber: true,
+	})
+	checkFatal(t, err)
+
+	assert.Equal(t, "1.0.500", r.currentVersion.String())
+	assert.Equal(t, "v1.0.500", r.currentTagName)
+	assert.Equal(t, runGit(t, repo, "rev-parse", "v1.0.500"), r.currentTag.ID.String())
+	assert.Equal(t, "1.0.501-rc.2", r.curPreReleaseVer.String())
+	assert.Equal(t, "1.0.501-rc.2", r.latestTagVersion.String())
+	assert.Equal(t, 1004, len(r.tags))
+	assert.Equal(t, "1.0.501-rc.3", r.LatestVersion())
+}
+
+// BenchmarkParseTags reports the git processes spawned by NewRepo on repositories with an increasing
+// number of version tags, which must not grow with the number of tags.
+func BenchmarkParseTags(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("%d tags", n), func(b *testing.B) {
+			tr := createTestRepo(b, "main")
+			repo, err := git.Open(tr)
+			checkFatal(b, err)
+
+			seedTestRepo(b, "v1.0.0", repo)
+			var tags []string
+			for i := 1; i <= n; i++ {
+				tags = append(tags, fmt.Sprintf("v1.0.%d", i), fmt.Sprintf("v1.0.%d-rc.1", i))
+			}
+			makeManyTags(b, repo, tags)
+			updateReadme(b, repo, "a fix")
+
+			trace := filepath.Join(b.TempDir(), "trace")
+			b.Setenv("GIT_TRACE", trace)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := NewRepo(GitRepoConfig{RepoPath: repo.Path(), Branch: "main"})
+				checkFatal(b, err)
+			}
+
+			b.StopTimer()
+			out, err := os.ReadFile(trace)
+			checkFatal(b, err)
+			b.ReportMetric(float64(strings.Count(string(out), "trace: built-in: git"))/float64(b.N), "git-calls/op")
+		})
+	}
+}
+
+func TestAllowedBranches(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		expectedErr string
+	}{
+		{
+			name: "any branch by default",
+		},
+		{
+			name:    "allowed branch",
+			allowed: []string{"release", "main"},
+		},
+		{
+			name:        "disallowed branch",
+			allowed:     []string{"release"},
+			expectedErr: "branch 'main' is not allowed to be tagged; allowed branches: release",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
 				initialTag: "v1.0.0",
-				extraTags:  []string{"v1.0.1-pre"},
-			},
-			expectedTag: "v1.0.1",
+				nextCommit: "a fix",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+
+			r.allowedBranches = tc.allowed
+			_, err = r.AutoTag()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Equal(t, "v1.0.0", runGit(t, r.repo, "tag", "--list"))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "v1.0.0\nv1.0.1", runGit(t, r.repo, "tag", "--list"))
+		})
+	}
+}
+
+func TestIgnoreTagsFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "no patterns",
+			content:  "# nothing ignored\n",
+			expected: "2.0.1",
 		},
 		{
-			name: "build metadata",
-			setup: testRepoSetup{
-				scheme:        "autotag",
-				nextCommit:    "#patch bump",
-				initialTag:    "v1.0.0",
-				buildMetadata: "g012345678",
-			},
-			expectedTag: "v1.0.1+g012345678",
+			name:     "matching tags are skipped",
+			content:  "# experiments\nv2.*\n\nlegacy-*\n",
+			expected: "1.1.1",
+		},
+		{
+			name:        "invalid pattern",
+			content:     "v[2\n",
+			expectedErr: ".autotag-ignore-tags pattern 'v[2' is not valid: syntax error in pattern",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "a feature")
+			makeTag(repo, "v1.1.0")
+			makeTag(repo, "v2.0.0")
+			updateReadme(t, repo, "a fix")
+
+			err = os.WriteFile(filepath.Join(tr, ".autotag-ignore-tags"), []byte(tc.content), 0o644)
+			checkFatal(t, err)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: tr,
+				Branch:   "main",
+			})
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestMetadataFromEnv(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		buildMetadata string
+		expected      string
+		expectedErr   string
+	}{
+		{
+			name:     "set variables",
+			env:      map[string]string{"BUILD_ID": "42", "GIT_REF": "feature/Login_Page"},
+			expected: "1.0.1+42.feature-Login-Page",
+		},
+		{
+			name:     "unset variables are skipped",
+			env:      map[string]string{"GIT_REF": "main"},
+			expected: "1.0.1+main",
+		},
+		{
+			name:     "all unset",
+			expected: "1.0.1",
+		},
+		{
+			name:          "after build metadata",
+			env:           map[string]string{"BUILD_ID": "42"},
+			buildMetadata: "ci",
+			expected:      "1.0.1+ci.42",
+		},
+		{
+			name:        "invalid value",
+			env:         map[string]string{"BUILD_ID": "1..2"},
+			expectedErr: "environment variable BUILD_ID value '1..2' is not valid SemVer build metadata",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"BUILD_ID", "GIT_REF"} {
+				t.Setenv(name, tc.env[name])
+			}
+
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "a fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:        repo.Path(),
+				Branch:          "main",
+				BuildMetadata:   tc.buildMetadata,
+				MetadataFromEnv: []string{"BUILD_ID", "GIT_REF"},
+			})
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestRejectAmbiguousDirectives(t *testing.T) {
+	tests := []struct {
+		name        string
+		commit      string
+		reject      bool
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:        "major and minor",
+			commit:      "[major] [minor] thing",
+			reject:      true,
+			expectedErr: "conflicting bump directives: major, minor",
+		},
+		{
+			name:     "repeated directive is not ambiguous",
+			commit:   "[minor] thing #minor",
+			reject:   true,
+			expected: "1.1.0",
+		},
+		{
+			name:     "largest bump wins by default",
+			commit:   "[major] [minor] thing",
+			expected: "2.0.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, tc.commit)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:                  repo.Path(),
+				Branch:                    "main",
+				RejectAmbiguousDirectives: tc.reject,
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), runGit(t, repo, "rev-parse", "HEAD")+" has "+tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestChannelOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		channelOrder   []string
+		expectedLatest string
+		expectedTags   []string
+	}{
+		{
+			name:           "lexical order",
+			expectedLatest: "1.1.0-dev.2",
+			expectedTags:   []string{"v1.1.0-dev.2", "v1.1.0-dev.1", "v1.1.0-canary.1", "v1.0.0"},
+		},
+		{
+			name:           "channel order",
+			channelOrder:   []string{"dev", "canary", "rc"},
+			expectedLatest: "1.1.0-canary.1",
+			expectedTags:   []string{"v1.1.0-canary.1", "v1.1.0-dev.2", "v1.1.0-dev.1", "v1.0.0"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "[minor] new feature")
+			makeTag(repo, "v1.1.0-dev.1")
+			makeTag(repo, "v1.1.0-dev.2")
+			makeTag(repo, "v1.1.0-canary.1")
+			updateReadme(t, repo, "a fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:     repo.Path(),
+				Branch:       "main",
+				ChannelOrder: tc.channelOrder,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expectedLatest, r.latestTagVersion.String())
+
+			var names []string
+			for _, tag := range r.tags {
+				names = append(names, tag.Name)
+			}
+			assert.Equal(t, tc.expectedTags, names)
+		})
+	}
+}
+
+func TestCompareTagVersionsUnlistedChannels(t *testing.T) {
+	r := GitRepo{channelOrder: []string{"zeta", "alpha"}, strategy: SemVerStrategy{}}
+	expected := []string{"1.1.0", "1.1.0-alpha.1", "1.1.0-zeta.2", "1.1.0-zeta.1", "1.1.0-gamma.1", "1.1.0-beta.1", "1.0.0"}
+
+	// every rotation of the input sorts the same, which needs a transitive order
+	for i := range expected {
+		var versions []*version.Version
+		for _, v := range append(append([]string{}, expected[i:]...), expected[:i]...) {
+			versions = append(versions, version.Must(version.NewVersion(v)))
+		}
+		sort.SliceStable(versions, func(i, j int) bool {
+			return r.compareTagVersions(versions[i], versions[j]) > 0
+		})
+
+		var names []string
+		for _, v := range versions {
+			names = append(names, v.String())
+		}
+		assert.Equal(t, expected, names)
+	}
+}
+
+func TestResetTrailer(t *testing.T) {
+	tests := []struct {
+		name        string
+		commits     []string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "bumps after the reset",
+			commits:  []string{"[major] breaking", "rewrite\n\nAutotag-Reset: 1.0.0", "[minor] new feature", "a fix"},
+			expected: "1.1.0",
 		},
 		{
-			name: "autotag scheme, [major] bump without prefix",
-			setup: testRepoSetup{
-				scheme:        "autotag",
-				nextCommit:    "[major] this is a big release\n\nfoo bar baz\n",
-				initialTag:    "1.0.0",
-				disablePrefix: true,
-			},
-			expectedTag: "2.0.0",
+			name:     "reset at the branch head",
+			commits:  []string{"[major] breaking", "rewrite\n\nAutotag-Reset: v1.0.0"},
+			expected: "1.0.0",
 		},
 		{
-			name: "autotag scheme, Bump with Major with interstitial minor changes",
-			setup: testRepoSetup{
-				scheme:        "autotag",
-				initialTag:    "1.0.0",
-				disablePrefix: true,
-				commitList: []string{
-					"#patch: thing 1",
-					"[minor]: break thing 1",
-					"feat: thing 2",
-					"[major]: drop support for Node 6",
-				},
-			},
-			expectedTag: "2.0.0",
+			name:     "resets to the same version",
+			commits:  []string{"rewrite\n\nAutotag-Reset: 3.0.0", "again\n\nAutotag-Reset: v3.0.0", "a fix"},
+			expected: "3.0.1",
 		},
 		{
-			name: "autotag scheme, Bump with Major between minor changes",
-			setup: testRepoSetup{
-				scheme:        "autotag",
-				initialTag:    "1.0.0",
-				disablePrefix: true,
-				commitList: []string{
-					"[minor]: thing 1",
-					"[major]: drop support for Node 6",
-					"[minor]: thing 2",
-				},
-			},
-			expectedTag: "2.0.0",
+			name:        "conflicting resets",
+			commits:     []string{"rewrite\n\nAutotag-Reset: 1.0.0", "again\n\nAutotag-Reset: 3.0.0", "a fix"},
+			expectedErr: "conflicting Autotag-Reset versions: ",
 		},
 		{
-			name: "autotag scheme, version comparison is not lexicographic",
-			setup: testRepoSetup{
-				scheme:     "autotag",
-				initialTag: "v0.9.0",
-				commitList: []string{
-					"[minor]: thing 1",
-					"[minor]: thing 2",
-				},
-			},
-			expectedTag: "v0.10.0",
+			name:     "no reset",
+			commits:  []string{"[minor] new feature"},
+			expected: "2.4.0",
 		},
-
-		// tests for conventional commits scheme. Based on:
-		// https://www.conventionalcommits.org/en/v1.0.0/#summary
-		// and
-		// https://www.conventionalcommits.org/en/v1.0.0/#examples
 		{
-			name: "conventional commits, minor bump without scope",
-			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "feat: allow provided config object to extend other configs",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v1.1.0",
+			name:        "invalid version",
+			commits:     []string{"rewrite\n\nAutotag-Reset: one"},
+			expectedErr: "has an invalid Autotag-Reset version 'one'",
 		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v2.3.0", repo)
+			for _, msg := range tc.commits {
+				updateReadme(t, repo, msg)
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: repo.Path(),
+				Branch:   "main",
+			})
+			if tc.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+		})
+	}
+}
+
+func TestResetTrailerReleases(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v2.3.0", repo)
+	release := func(expected string) {
+		t.Helper()
+		r, err := NewRepo(GitRepoConfig{
+			RepoPath: repo.Path(),
+			Branch:   "main",
+			Prefix:   true,
+		})
+		checkFatal(t, err)
+		result, err := r.AutoTag()
+		checkFatal(t, err)
+		assert.Equal(t, expected, result.Tag)
+	}
+
+	// the older, higher tag is no longer the base once the reset version is released
+	updateReadme(t, repo, "rewrite\n\nAutotag-Reset: 1.0.0")
+	release("v1.0.0")
+	updateReadme(t, repo, "a fix")
+	release("v1.0.1")
+	updateReadme(t, repo, "another fix")
+	release("v1.0.2")
+
+	// a released reset doesn't conflict with a newer one
+	updateReadme(t, repo, "another rewrite\n\nAutotag-Reset: 5.0.0")
+	updateReadme(t, repo, "a fix")
+	release("v5.0.1")
+	updateReadme(t, repo, "a fix")
+	release("v5.0.2")
+}
+
+// makeManyCommits appends empty commits with the messages to the main branch in one fast-import call
+func makeManyCommits(tb testing.TB, repo *git.Repository, msgs []string) {
+	var stream bytes.Buffer
+	for i, msg := range msgs {
+		fmt.Fprintf(&stream, "commit refs/heads/main\ncommitter autotag <autotag@example.com> %d +0000\ndata %d\n%s\n", 1546300800+i, len(msg), msg)
+		if i == 0 {
+			stream.WriteString("from refs/heads/main^0\n")
+		}
+	}
+	cmd := exec.Command("git", "fast-import", "--quiet")
+	cmd.Dir = repoRoot(repo)
+	cmd.Stdin = &stream
+	if out, err := cmd.CombinedOutput(); err != nil {
+		tb.Fatalf("fast-import failed: %s: %s", err, out)
+	}
+}
+
+// manyCommitMessages returns messages for a large range with a few bump directives spread across it
+func manyCommitMessages(n int) []string {
+	msgs := make([]string, n)
+	for i := range msgs {
+		switch {
+		case i == n/2:
+			msgs[i] = fmt.Sprintf("[major] breaking change %d", i)
+		case i%97 == 0:
+			msgs[i] = fmt.Sprintf("[minor] feature %d", i)
+		default:
+			msgs[i] = fmt.Sprintf("fix %d", i)
+		}
+	}
+	return msgs
+}
+
+func TestParallelCommitParsing(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	makeManyCommits(t, repo, manyCommitMessages(500))
+
+	// the worker pool is only used with more than one processor
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	newRepo := func(threshold int, cfg GitRepoConfig) (*GitRepo, error) {
+		defer func(prev int) { parallelParseThreshold = prev }(parallelParseThreshold)
+		parallelParseThreshold = threshold
+		cfg.RepoPath = repo.Path()
+		cfg.Branch = "main"
+		return NewRepo(cfg)
+	}
+
+	cfg := GitRepoConfig{CreateIntermediateTags: true, LockMajor: true}
+	sequential, err := newRepo(1<<30, cfg)
+	checkFatal(t, err)
+	parallel, err := newRepo(1, cfg)
+	checkFatal(t, err)
+
+	assert.Equal(t, sequential.LatestVersion(), parallel.LatestVersion())
+	assert.Equal(t, sequential.MatchDetails(), parallel.MatchDetails())
+	assert.Equal(t, sequential.Stats(), parallel.Stats())
+	assert.Equal(t, sequential.intermediateTags, parallel.intermediateTags)
+	assert.Equal(t, 500, len(parallel.MatchDetails()))
+
+	// the error of the oldest failing commit is reported
+	cfg = GitRepoConfig{StrictMatch: true}
+	_, seqErr := newRepo(1<<30, cfg)
+	_, parErr := newRepo(1, cfg)
+	assert.Error(t, seqErr)
+	assert.Equal(t, seqErr.Error(), parErr.Error())
+}
+
+// BenchmarkMatchCommits measures matching a large range of commits with the commit message scheme,
+// without reading them from git
+func BenchmarkMatchCommits(b *testing.B) {
+	tr := createTestRepo(b, "main")
+	repo, err := git.Open(tr)
+	checkFatal(b, err)
+
+	seedTestRepo(b, "v1.0.0", repo)
+	makeManyCommits(b, repo, manyCommitMessages(2000))
+
+	r, err := NewRepo(GitRepoConfig{RepoPath: repo.Path(), Branch: "main", Scheme: "conventional"})
+	checkFatal(b, err)
+	commits, err := repo.RevList([]string{"v1.0.0..main"})
+	checkFatal(b, err)
+
+	for _, tc := range []struct {
+		name      string
+		threshold int
+	}{{"sequential", 1 << 30}, {"parallel", 1}} {
+		b.Run(tc.name, func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+			defer func(prev int) { parallelParseThreshold = prev }(parallelParseThreshold)
+			parallelParseThreshold = tc.threshold
+			log.SetOutput(io.Discard)
+			defer log.SetOutput(os.Stderr)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.matchCommits(commits)
+			}
+		})
+	}
+}
+
+func TestStableChannel(t *testing.T) {
+	tests := []struct {
+		name            string
+		stableChannel   string
+		preReleaseName  string
+		expectedBase    string
+		expectedVersion string
+	}{
 		{
-			name: "conventional commits, minor bump with scope",
-			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "feat(lang): add polish language",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v1.1.0",
+			name:            "unmarked tags are stable",
+			expectedBase:    "1.1.0",
+			expectedVersion: "1.1.1",
 		},
 		{
-			name: "conventional commits, breaking change via ! appended to type",
-			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "refactor!: drop support for Node 6",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v2.0.0",
+			name:            "only marked tags are stable",
+			stableChannel:   "stable",
+			expectedBase:    "1.0.0+stable",
+			expectedVersion: "1.0.1+stable",
 		},
 		{
-			name: "conventional commits, breaking change via ! appended to type/scope",
-			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "refactor(runtime)!: drop support for Node 6",
-				initialTag: "v1.0.0",
-			},
-			expectedTag: "v2.0.0",
+			name:            "pre-release is not marked",
+			stableChannel:   "stable",
+			preReleaseName:  "rc",
+			expectedBase:    "1.0.0+stable",
+			expectedVersion: "1.0.1-rc",
 		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0+stable", repo)
+			updateReadme(t, repo, "unmarked release")
+			makeTag(repo, "v1.1.0")
+			updateReadme(t, repo, "a fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:       repo.Path(),
+				Branch:         "main",
+				StableChannel:  tc.stableChannel,
+				PreReleaseName: tc.preReleaseName,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expectedBase, r.currentVersion.String())
+			assert.Equal(t, tc.expectedVersion, r.LatestVersion())
+		})
+	}
+}
+
+func TestBumpType(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    testRepoSetup
+		expected BumpType
+	}{
 		{
-			name: "conventional commits, breaking change via footer",
+			name: "major",
 			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "feat: allow provided config object to extend other configs\n\nbody before footer\n\nBREAKING CHANGE: non-backwards compatible",
-				initialTag: "v1.0.0",
+				initialTag: "v1.2.2",
+				nextCommit: "[major] this is a big release",
 			},
-			expectedTag: "v2.0.0",
+			expected: BumpMajor,
 		},
 		{
-			name: "conventional commits, patch/minor bump",
+			name: "minor",
 			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "fix: correct minor typos in code",
-				initialTag: "v1.0.0",
+				initialTag: "v1.2.2",
+				nextCommit: "[minor] this is a smaller release",
 			},
-			expectedTag: "v1.0.1",
+			expected: BumpMinor,
 		},
 		{
-			name: "conventional commits, non-conforming fallback to patch bump",
+			name: "patch",
 			setup: testRepoSetup{
-				scheme:     "conventional",
-				nextCommit: "not a conventional commit message",
-				initialTag: "v1.0.0",
+				initialTag: "v1.2.2",
+				nextCommit: "this is just a basic change",
 			},
-			expectedTag: "v1.0.1",
+			expected: BumpPatch,
 		},
 		{
-			name: "conventional commits, breaking change with minor interstitial commits",
+			name: "highest bump of the commits",
 			setup: testRepoSetup{
-				scheme: "conventional",
-				commitList: []string{
-					"feat: thing 1",
-					"feat!: break thing 1",
-					"feat: thing 2",
-					"refactor(runtime)!: drop support for Node 6",
-				},
-				initialTag: "v1.0.0",
+				initialTag: "v1.2.2",
+				commitList: []string{"[minor] feature", "fix", "#major breaking"},
 			},
-			expectedTag: "v2.0.0",
+			expected: BumpMajor,
 		},
 		{
-			name: "conventional commits, breaking change between minor commits",
+			name: "pre-release recalculation reports the underlying bump",
 			setup: testRepoSetup{
-				scheme: "conventional",
-				commitList: []string{
-					"feat: thing 1",
-					"feat!: break thing 1",
-					"feat: thing 2",
-				},
-				initialTag: "v1.0.0",
+				initialTag:     "v1.2.2",
+				extraTags:      []string{"v1.3.0-dev.1"},
+				nextCommit:     "this is just a basic change",
+				preReleaseName: "dev",
 			},
-			expectedTag: "v2.0.0",
+			expected: BumpPatch,
 		},
 		{
-			name: "conventional commits, version comparison is not lexicographic",
+			name: "reset commit",
 			setup: testRepoSetup{
-				scheme: "conventional",
-				commitList: []string{
-					"feat: thing 1",
-					"feat: thing 2",
-				},
-				initialTag: "v0.9.0",
+				initialTag: "v1.2.2",
+				nextCommit: "restart\n\nAutotag-Reset: 3.0.0",
 			},
-			expectedTag: "v0.10.0",
+			expected: BumpNone,
 		},
 	}
 
@@ -873,52 +6093,100 @@ func TestAutoTag(t *testing.T) {
 			checkFatal(t, err)
 			defer cleanupTestRepo(t, r.repo)
 
-			err = r.AutoTag()
-			if tc.shouldErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
+			assert.Equal(t, tc.expected, r.BumpType())
 
-			tags, err := r.repo.Tags()
-			checkFatal(t, err)
-			assert.SliceContains(t, tags, tc.expectedTag)
+			// every report of the bump agrees with BumpType
+			assert.Equal(t, string(tc.expected), r.Stats().Bump)
+			var summary bytes.Buffer
+			checkFatal(t, r.WriteSummary(&summary, "json"))
+			assert.Contains(t, summary.String(), fmt.Sprintf(`"bump": "%s"`, tc.expected))
+			if tc.expected != BumpNone {
+				assert.Contains(t, r.BumpMessage(), fmt.Sprintf("(%s)", tc.expected))
+			}
 		})
 	}
 }
 
-func TestValidateSemVerBuildMetadata(t *testing.T) {
+func TestCalculateVersion(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[minor] new feature")
+
+	state := filepath.Join(t.TempDir(), "autotag.state")
+	cfg := GitRepoConfig{
+		RepoPath:  repo.Path(),
+		Branch:    "main",
+		StateFile: state,
+	}
+
+	v, bump, err := CalculateVersion(cfg)
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", v.String())
+	assert.Equal(t, BumpMinor, bump)
+
+	// nothing is written
+	assert.Equal(t, "v1.0.0", runGit(t, repo, "tag", "--list"))
+	_, err = os.Stat(state)
+	assert.True(t, os.IsNotExist(err), "state file should not be written")
+
+	// the same version as NewRepo
+	r, err := NewRepo(cfg)
+	checkFatal(t, err)
+	assert.Equal(t, r.LatestVersion(), v.String())
+	assert.Equal(t, r.BumpType(), bump)
+
+	_, _, err = CalculateVersion(GitRepoConfig{RepoPath: repo.Path(), Branch: "main", Scheme: "other"})
+	assert.Error(t, err)
+}
+
+func TestBumpTypeReports(t *testing.T) {
 	tests := []struct {
-		name  string
-		meta  string
-		valid bool
+		name     string
+		tag      string
+		cfg      GitRepoConfig
+		expected BumpType
 	}{
 		{
-			name:  "valid single-part metadata",
-			meta:  "g123456",
-			valid: true,
-		},
-		{
-			name:  "valid multi-part metadata",
-			meta:  "g123456.20200512",
-			valid: true,
-		},
-		{
-			name:  "invalid characters",
-			meta:  "g123456,foo_bar",
-			valid: false,
+			// the train moves the minor version, the commit only asks for a patch
+			name:     "release train",
+			tag:      "v1.201852.3",
+			cfg:      GitRepoConfig{ReleaseTrain: "isoweek"},
+			expected: BumpPatch,
 		},
 		{
-			name:  "empty string",
-			meta:  "",
-			valid: false,
+			// the core of the version does not change when the base pre-release is finalized
+			name:     "pre-release base",
+			tag:      "v1.0.0-rc.1",
+			cfg:      GitRepoConfig{AllowPreReleaseBase: true},
+			expected: BumpPatch,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			valid := validateSemVerBuildMetadata(tc.meta)
-			assert.Equal(t, tc.valid, valid)
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, tc.tag, repo)
+			updateReadme(t, repo, "a fix")
+
+			tc.cfg.RepoPath = repo.Path()
+			tc.cfg.Branch = "main"
+			r, err := NewRepo(tc.cfg)
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.BumpType())
+			assert.Equal(t, string(tc.expected), r.Stats().Bump)
+
+			var summary bytes.Buffer
+			checkFatal(t, r.WriteSummary(&summary, "json"))
+			assert.Contains(t, summary.String(), fmt.Sprintf(`"bump": "%s"`, tc.expected))
+			assert.Contains(t, r.BumpMessage(), fmt.Sprintf("(%s)", tc.expected))
 		})
 	}
 }
@@ -6,8 +6,20 @@ import (
 	"github.com/hashicorp/go-version"
 )
 
+// BumpType is the bump the new version was calculated with, see GitRepo.BumpType.
+type BumpType string
+
+const (
+	BumpNone  BumpType = "none"
+	BumpPatch BumpType = "patch"
+	BumpMinor BumpType = "minor"
+	BumpMajor BumpType = "major"
+)
+
 type bumper interface {
 	bump(*version.Version) (*version.Version, error)
+	// bumpWith applies the same bump level using the given VersionStrategy
+	bumpWith(VersionStrategy, *version.Version) (*version.Version, error)
 }
 
 type major struct{}
@@ -18,6 +30,13 @@ var (
 	majorBumper major
 	minorBumper minor
 	patchBumper patch
+
+	// namedBumpers looks up the bumpers by name, eg: in the bump file
+	namedBumpers = map[string]bumper{
+		"major": majorBumper,
+		"minor": minorBumper,
+		"patch": patchBumper,
+	}
 )
 
 func (m major) bump(cv *version.Version) (*version.Version, error) {
@@ -64,3 +83,32 @@ func (m patch) bump(cv *version.Version) (*version.Version, error) {
 	}
 	return version.NewVersion(vString)
 }
+
+func (m major) bumpWith(s VersionStrategy, cv *version.Version) (*version.Version, error) {
+	return s.BumpMajor(cv)
+}
+
+func (m minor) bumpWith(s VersionStrategy, cv *version.Version) (*version.Version, error) {
+	return s.BumpMinor(cv)
+}
+
+func (m patch) bumpWith(s VersionStrategy, cv *version.Version) (*version.Version, error) {
+	return s.BumpPatch(cv)
+}
+
+// bumpRank orders the bumps by significance, a nil bump ranks lowest
+func bumpRank(b bumper) int {
+	switch b.(type) {
+	case major:
+		return 3
+	case minor:
+		return 2
+	case patch:
+		return 1
+	}
+	return 0
+}
+
+func (major) String() string { return "major" }
+func (minor) String() string { return "minor" }
+func (patch) String() string { return "patch" }
@@ -0,0 +1,201 @@
+package autotag
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gogs/git-module"
+)
+
+// pullRequestRex matches the pull or merge request number in a merge commit message, eg:
+// `Merge pull request #123 from org/branch` or `See merge request group/repo!123`
+var pullRequestRex = regexp.MustCompile(`(?i)(?:pull request #|merge request [^\s!]*!)(\d+)`)
+
+// closingRex matches the issues closed by a commit, eg: `Closes #123` or `Fixes: #4, #5`
+var closingRex = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?[ \t]+((?:#\d+(?:[ \t]*,[ \t]*|[ \t]+and[ \t]+)?)+)`)
+
+// issueRex matches an issue number in a closingRex match
+var issueRex = regexp.MustCompile(`#\d+`)
+
+// ChangelogGroup is an entry of the GroupedChangelog.
+type ChangelogGroup struct {
+	// Subject is the first line of the commit message, of the merge commit with ChangelogByPR.
+	Subject string
+
+	// SHA is the ID of the commit.
+	SHA string
+
+	// PR is the pull request number parsed from a merge commit message, 0 if there is none.
+	PR int
+
+	// Commits are the subjects of the commits brought in by a merge commit with ChangelogByPR,
+	// oldest first.
+	Commits []string
+}
+
+// CommitSummary is a commit returned by CommitsSince.
+type CommitSummary struct {
+	// SHA is the ID of the commit.
+	SHA string
+
+	// Subject is the first line of the commit message.
+	Subject string
+
+	// Author is the name of the commit author.
+	Author string
+
+	// Date is the author or committer date of the commit, see DateSource.
+	Date time.Time
+}
+
+// CommitsSince returns the commits from the tag of a prior version, eg: `1.2.0`, to the branch head,
+// oldest first. Unlike the changelog of the release it can span several versions, eg: for release
+// notes covering versions that were never announced. Build metadata of the version is ignored.
+func (r *GitRepo) CommitsSince(v string) ([]CommitSummary, error) {
+	since, err := r.strategy.Parse(v)
+	if err != nil || since == nil {
+		return nil, fmt.Errorf("'%s' is not a valid version", v)
+	}
+
+	tag := ""
+	for _, t := range r.tags {
+		if t.Version.Equal(since) {
+			tag = t.Name
+			break
+		}
+	}
+	if tag == "" {
+		return nil, fmt.Errorf("no tag found for version '%s'", v)
+	}
+
+	l, err := r.revList(fmt.Sprintf("%s..%s", r.tagRef(tag), r.branchID))
+	if err != nil {
+		return nil, fmt.Errorf("error loading history for tag '%s': %s", tag, err)
+	}
+
+	commits := make([]CommitSummary, 0, len(l))
+	for i := len(l) - 1; i >= 0; i-- {
+		commits = append(commits, CommitSummary{
+			SHA:     l[i].ID.String(),
+			Subject: l[i].Summary(),
+			Author:  l[i].Author.Name,
+			Date:    r.commitDate(l[i]),
+		})
+	}
+	return commits, nil
+}
+
+// GroupedChangelog returns the commits in the release, oldest first. Each commit is its own group,
+// unless ChangelogByPR is enabled: then the commits brought in by a merge commit on the branch are
+// grouped under it, with the pull request number parsed from its message.
+func (r *GitRepo) GroupedChangelog() ([]ChangelogGroup, error) {
+	l, err := r.releaseCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	// l is newest first, the position orders the commits
+	order := make(map[string]int, len(l))
+	byID := make(map[string]*git.Commit, len(l))
+	for i, c := range l {
+		order[c.ID.String()] = len(l) - i
+		byID[c.ID.String()] = c
+	}
+
+	if !r.changelogByPR {
+		groups := make([]ChangelogGroup, 0, len(l))
+		for i := len(l) - 1; i >= 0; i-- {
+			groups = append(groups, ChangelogGroup{Subject: l[i].Summary(), SHA: l[i].ID.String()})
+		}
+		return groups, nil
+	}
+
+	// the branch itself follows the first parents from the head
+	var mainline []*git.Commit
+	claimed := make(map[string]bool)
+	for c := byID[r.branchID]; c != nil; {
+		mainline = append(mainline, c)
+		claimed[c.ID.String()] = true
+		if c.ParentsCount() == 0 {
+			break
+		}
+		id, err := c.ParentID(0)
+		if err != nil {
+			return nil, err
+		}
+		c = byID[id.String()]
+	}
+
+	groups := make([]ChangelogGroup, 0, len(mainline))
+	for i := len(mainline) - 1; i >= 0; i-- {
+		c := mainline[i]
+		g := ChangelogGroup{Subject: c.Summary(), SHA: c.ID.String()}
+		if c.ParentsCount() > 1 {
+			if m := pullRequestRex.FindStringSubmatch(c.Message); m != nil {
+				g.PR, _ = strconv.Atoi(m[1])
+			}
+
+			// the commits only reachable from the merged parents, older merges claim theirs first
+			var merged []*git.Commit
+			var queue []string
+			for n := 1; n < c.ParentsCount(); n++ {
+				id, err := c.ParentID(n)
+				if err != nil {
+					return nil, err
+				}
+				queue = append(queue, id.String())
+			}
+			for len(queue) > 0 {
+				id := queue[0]
+				queue = queue[1:]
+				p, ok := byID[id]
+				if !ok || claimed[id] {
+					continue
+				}
+				claimed[id] = true
+				merged = append(merged, p)
+				for n := 0; n < p.ParentsCount(); n++ {
+					pid, err := p.ParentID(n)
+					if err != nil {
+						return nil, err
+					}
+					queue = append(queue, pid.String())
+				}
+			}
+			sort.Slice(merged, func(i, j int) bool {
+				return order[merged[i].ID.String()] < order[merged[j].ID.String()]
+			})
+			for _, m := range merged {
+				g.Commits = append(g.Commits, m.Summary())
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// closedIssues returns the issues and pull requests closed by the commits in the release, eg: `#123`,
+// in the order they are first referenced from the oldest commit
+func (r *GitRepo) closedIssues() ([]string, error) {
+	l, err := r.releaseCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []string
+	seen := make(map[string]bool)
+	for i := len(l) - 1; i >= 0; i-- {
+		for _, m := range closingRex.FindAllStringSubmatch(l[i].Message, -1) {
+			for _, issue := range issueRex.FindAllString(m[1], -1) {
+				if !seen[issue] {
+					seen[issue] = true
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,143 @@
+package autotag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+	"github.com/gogs/git-module"
+)
+
+func TestGroupedChangelog(t *testing.T) {
+	tests := []struct {
+		name     string
+		byPR     bool
+		expected []ChangelogGroup
+	}{
+		{
+			name: "by commit",
+			expected: []ChangelogGroup{
+				{Subject: "feature part 1"},
+				{Subject: "unrelated change on main"},
+				{Subject: "feature part 2"},
+				{Subject: "Merge pull request #12 from org/feature"},
+				{Subject: "hotfix"},
+			},
+		},
+		{
+			name: "by pull request",
+			byPR: true,
+			expected: []ChangelogGroup{
+				{Subject: "unrelated change on main"},
+				{Subject: "Merge pull request #12 from org/feature", PR: 12, Commits: []string{"feature part 1", "feature part 2"}},
+				{Subject: "hotfix"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			// distinct commit dates keep the history order stable
+			minute := 0
+			at := func() {
+				minute++
+				date := fmt.Sprintf("2018-12-31T12:%02d:00Z", minute)
+				t.Setenv("GIT_AUTHOR_DATE", date)
+				t.Setenv("GIT_COMMITTER_DATE", date)
+			}
+
+			at()
+			seedTestRepo(t, "v1.0.0", repo)
+			runGit(t, repo, "checkout", "-q", "-b", "feature")
+			at()
+			updateReadme(t, repo, "feature part 1")
+			runGit(t, repo, "checkout", "-q", "main")
+			at()
+			checkFatal(t, os.WriteFile(filepath.Join(tr, "OTHER"), []byte("other\n"), 0o644))
+			makeCommit(repo, "unrelated change on main")
+			runGit(t, repo, "checkout", "-q", "feature")
+			at()
+			updateReadme(t, repo, "feature part 2")
+			runGit(t, repo, "checkout", "-q", "main")
+			at()
+			runGit(t, repo, "merge", "--no-ff", "feature", "-m", "Merge pull request #12 from org/feature")
+			at()
+			updateReadme(t, repo, "hotfix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:      repo.Path(),
+				Branch:        "main",
+				ChangelogByPR: tc.byPR,
+			})
+			checkFatal(t, err)
+
+			groups, err := r.GroupedChangelog()
+			checkFatal(t, err)
+			for i := range groups {
+				assert.NotZero(t, groups[i].SHA)
+				groups[i].SHA = ""
+			}
+			assert.Equal(t, tc.expected, groups)
+		})
+	}
+}
+
+func TestPullRequestNumber(t *testing.T) {
+	tests := map[string]string{
+		"Merge pull request #12 from org/feature":                               "12",
+		"Merge branch 'feature' into 'main'\n\nSee merge request group/repo!34": "34",
+	}
+	for msg, expected := range tests {
+		m := pullRequestRex.FindStringSubmatch(msg)
+		assert.Equal(t, []string{m[0], expected}, m)
+	}
+	assert.Zero(t, pullRequestRex.FindStringSubmatch("Merge branch 'feature'"))
+}
+
+func TestCommitsSince(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "first fix")
+	makeTag(repo, "v1.0.1")
+	// v1.0.2 was never tagged
+	updateReadme(t, repo, "second fix")
+	updateReadme(t, repo, "third fix")
+	makeTag(repo, "v1.0.3")
+	updateReadme(t, repo, "fourth fix")
+
+	r, err := NewRepo(GitRepoConfig{RepoPath: repo.Path(), Branch: "main"})
+	checkFatal(t, err)
+
+	commits, err := r.CommitsSince("1.0.1")
+	checkFatal(t, err)
+	subjects := make([]string, 0, len(commits))
+	for _, c := range commits {
+		assert.Equal(t, 40, len(c.SHA))
+		assert.NotZero(t, c.Author)
+		assert.NotZero(t, c.Date)
+		subjects = append(subjects, c.Subject)
+	}
+	assert.Equal(t, []string{"second fix", "third fix", "fourth fix"}, subjects)
+
+	commits, err = r.CommitsSince("v1.0.0")
+	checkFatal(t, err)
+	assert.Equal(t, 4, len(commits))
+	assert.Equal(t, "first fix", commits[0].Subject)
+
+	_, err = r.CommitsSince("1.0.2")
+	assert.EqualError(t, err, "no tag found for version '1.0.2'")
+
+	_, err = r.CommitsSince("latest")
+	assert.EqualError(t, err, "'latest' is not a valid version")
+}
@@ -7,12 +7,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/gogs/git-module"
 )
 
-func checkFatal(t *testing.T, err error) {
+func checkFatal(t testing.TB, err error) {
 	if err == nil {
 		return
 	}
@@ -25,7 +26,7 @@ func checkFatal(t *testing.T, err error) {
 	t.Fatalf("Fail at %v:%v; %v", file, line, err)
 }
 
-func createTestRepo(t *testing.T, branch string) string {
+func createTestRepo(t testing.TB, branch string) string {
 	// figure out where we can create the test repo
 	tmp := t.TempDir()
 	path := filepath.Join(tmp, "autoTagTest")
@@ -78,6 +79,19 @@ func makeCommit(r *git.Repository, msg string) {
 	}
 }
 
+// runGit runs an arbitrary git command in the root of the repo, failing the test on error.
+func runGit(t testing.TB, r *git.Repository, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoRoot(r)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
 func makeTag(r *git.Repository, tag string) {
 	p := repoRoot(r)
 	cmd := exec.Command("git", "tag", tag)
@@ -89,7 +103,7 @@ func makeTag(r *git.Repository, tag string) {
 	}
 }
 
-func seedTestRepo(t *testing.T, tag string, repo *git.Repository) {
+func seedTestRepo(t testing.TB, tag string, repo *git.Repository) {
 	f := repoRoot(repo) + "/README"
 	err := exec.Command("touch", f).Run()
 	if err != nil {
@@ -101,7 +115,7 @@ func seedTestRepo(t *testing.T, tag string, repo *git.Repository) {
 	makeTag(repo, tag)
 }
 
-func updateReadme(t *testing.T, repo *git.Repository, content string) {
+func updateReadme(t testing.TB, repo *git.Repository, content string) {
 	tmpfile := repoRoot(repo) + "/README"
 	err := os.WriteFile(tmpfile, []byte(content), 0o644)
 	checkFatal(t, err)
@@ -121,3 +135,33 @@ func repoRoot(r *git.Repository) string {
 	}
 	return p
 }
+
+// setupGPG creates a throwaway GPG home with an unprotected signing key and points git at it for the
+// duration of the test. It returns the signing key identity, or skips the test if gpg is not available.
+func setupGPG(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available in PATH")
+	}
+
+	identity := "autotag@example.com"
+	t.Setenv("GNUPGHOME", t.TempDir())
+
+	out, err := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "autotag test <"+identity+">", "default", "default", "never").CombinedOutput()
+	if err != nil {
+		t.Fatalf("gpg key generation failed: %s: %s", err, out)
+	}
+	return identity
+}
+
+func makeSignedTag(r *git.Repository, tag, key string) {
+	p := repoRoot(r)
+	cmd := exec.Command("git", "-c", "user.signingkey="+key, "tag", "-s", "-m", tag, tag)
+	cmd.Dir = p
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Println("signed tag creation failed: ", string(out))
+		fmt.Println(err)
+	}
+}
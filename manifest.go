@@ -0,0 +1,91 @@
+package autotag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// renameFile is used to atomically replace the manifest, and can be swapped out in tests
+var renameFile = os.Rename
+
+// ManifestRecord is a single release entry appended to the ManifestFile.
+type ManifestRecord struct {
+	Version string `json:"version"`
+	Tag     string `json:"tag"`
+	SHA     string `json:"sha"`
+	Date    string `json:"date"`
+	Bump    string `json:"bump"` // see GitRepo.BumpType
+}
+
+// readManifest loads the records in a manifest file. A missing file has no records.
+func readManifest(path string) ([]ManifestRecord, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ManifestRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("error parsing manifest '%s': %s", path, err)
+	}
+	return records, nil
+}
+
+// appendManifest appends a record to the manifest file. The new manifest is written to a temporary
+// file in the same directory and renamed over the old one, so an interrupted write never leaves a
+// truncated manifest behind.
+func appendManifest(path string, record ManifestRecord) error {
+	records, err := readManifest(path)
+	if err != nil {
+		return err
+	}
+	records = append(records, record)
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return renameFile(tmp.Name(), path)
+}
+
+// recordRelease appends the tagged release to the configured ManifestFile.
+func (r *GitRepo) recordRelease(tagName string) error {
+	err := appendManifest(r.manifestFile, ManifestRecord{
+		Version: r.strategy.Format(r.newVersion),
+		Tag:     tagName,
+		SHA:     r.branchID,
+		Date:    timeNow().UTC().Format(time.RFC3339),
+		Bump:    r.bumpName(),
+	})
+	if err != nil {
+		return fmt.Errorf("error writing manifest '%s': %s", r.manifestFile, err)
+	}
+	return nil
+}
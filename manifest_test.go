@@ -0,0 +1,67 @@
+package autotag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+)
+
+func TestManifestFile(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "releases.json")
+
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "[minor] first release",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	r.manifestFile = manifest
+	_, err = r.AutoTag()
+	assert.NoError(t, err)
+
+	// second release on top of the first
+	updateReadme(t, r.repo, "[major] second release")
+	next, err := NewRepo(GitRepoConfig{
+		RepoPath:     repoRoot(r.repo),
+		Branch:       "main",
+		Prefix:       true,
+		ManifestFile: manifest,
+	})
+	checkFatal(t, err)
+	_, err = next.AutoTag()
+	assert.NoError(t, err)
+
+	records, err := readManifest(manifest)
+	checkFatal(t, err)
+	assert.Equal(t, []ManifestRecord{
+		{Version: "1.1.0", Tag: "v1.1.0", SHA: r.branchID, Date: "2019-01-01T00:00:00Z", Bump: "minor"},
+		{Version: "2.0.0", Tag: "v2.0.0", SHA: next.branchID, Date: "2019-01-01T00:00:00Z", Bump: "major"},
+	}, records)
+}
+
+func TestManifestFileInterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "releases.json")
+
+	first := ManifestRecord{Version: "1.0.0", Tag: "v1.0.0", SHA: "abc", Date: "2019-01-01T00:00:00Z", Bump: "patch"}
+	checkFatal(t, appendManifest(manifest, first))
+
+	// simulate the process dying between writing the temporary file and renaming it into place
+	renameFile = func(string, string) error { return errors.New("interrupted") }
+	defer func() { renameFile = os.Rename }()
+
+	err := appendManifest(manifest, ManifestRecord{Version: "1.0.1", Tag: "v1.0.1", SHA: "def", Bump: "patch"})
+	assert.Error(t, err)
+
+	records, err := readManifest(manifest)
+	checkFatal(t, err)
+	assert.Equal(t, []ManifestRecord{first}, records)
+
+	entries, err := os.ReadDir(dir)
+	checkFatal(t, err)
+	assert.Equal(t, 1, len(entries), "temporary manifest should be cleaned up")
+}
@@ -0,0 +1,61 @@
+package autotag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Publisher publishes a release for a newly created tag, eg: a GitHub or GitLab release. This package
+// ships no implementation so it stays independent of any VCS host; implement it in a separate module
+// and set it in GitRepoConfig.
+type Publisher interface {
+	// Publish is called with the name of the new tag and a changelog listing the subject of every
+	// commit in the release, oldest first.
+	Publish(ctx context.Context, tag, changelog string) error
+}
+
+// publish calls the configured Publisher for the new tag
+func (r *GitRepo) publish() error {
+	changelog, err := r.changelog()
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.tagName(r.newVersion)
+	if err != nil {
+		return err
+	}
+	if err := r.publisher.Publish(context.Background(), tag, changelog); err != nil {
+		return fmt.Errorf("error publishing release '%s': %s", tag, err)
+	}
+	return nil
+}
+
+// changelog returns a markdown list of the commit subjects in the release, oldest first
+func (r *GitRepo) changelog() (string, error) {
+	subjects, err := r.releaseSubjects()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, s := range subjects {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	return b.String(), nil
+}
+
+// releaseSubjects returns the subjects of the commits in the release, oldest first
+func (r *GitRepo) releaseSubjects() ([]string, error) {
+	l, err := r.releaseCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	subjects := make([]string, 0, len(l))
+	for i := len(l) - 1; i >= 0; i-- {
+		subjects = append(subjects, l[i].Summary())
+	}
+	return subjects, nil
+}
@@ -0,0 +1,57 @@
+package autotag
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+)
+
+type fakePublisher struct {
+	tag       string
+	changelog string
+	calls     int
+	err       error
+}
+
+func (p *fakePublisher) Publish(_ context.Context, tag, changelog string) error {
+	p.calls++
+	p.tag = tag
+	p.changelog = changelog
+	return p.err
+}
+
+func TestPublisher(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		commitList: []string{"[minor] new feature", "fix a bug"},
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	p := &fakePublisher{}
+	r.publisher = p
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+
+	assert.Equal(t, 1, p.calls)
+	assert.Equal(t, "v1.1.0", p.tag)
+	assert.Equal(t, "- [minor] new feature\n- fix a bug\n", p.changelog)
+}
+
+func TestPublisherError(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		nextCommit: "fix a bug",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	r.publisher = &fakePublisher{err: errors.New("unauthorized")}
+	_, err = r.AutoTag()
+	assert.EqualError(t, err, "error publishing release 'v1.0.1': unauthorized")
+
+	// the tag is kept so the release can be published again by hand
+	assert.Equal(t, "v1.0.0\nv1.0.1", runGit(t, r.repo, "tag", "--list"))
+}
@@ -0,0 +1,57 @@
+package autotag
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gogs/git-module"
+)
+
+// stateRex matches the full commit SHA recorded in the StateFile
+var stateRex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// loadState reads the commit processed by the previous run from the StateFile, the start of the commits
+// reported as new since then. A missing file, or a commit that is no longer between the base tag and the
+// branch head, eg: after a rebase, falls back to the base tag.
+func (r *GitRepo) loadState() error {
+	data, err := os.ReadFile(r.stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No state in '%s', checking commits since the base tag", r.stateFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sha := strings.TrimSpace(string(data))
+	if sha == "" {
+		return nil
+	}
+	if !stateRex.MatchString(sha) {
+		return fmt.Errorf("state file '%s' does not contain a commit SHA", r.stateFile)
+	}
+	if _, err := git.NewCommand("merge-base", "--is-ancestor", r.currentTag.ID.String(), sha).RunInDir(r.repo.Path()); err != nil {
+		log.Printf("Ignoring state commit %s, it does not follow the base tag", sha)
+		return nil
+	}
+	if _, err := git.NewCommand("merge-base", "--is-ancestor", sha, r.branchID).RunInDir(r.repo.Path()); err != nil {
+		log.Printf("Ignoring state commit %s, it is not on branch '%s'", sha, r.branch)
+		return nil
+	}
+
+	r.rangeStart = sha
+	return nil
+}
+
+// saveState records the branch head in the StateFile as the start of the commits the next run reports
+func (r *GitRepo) saveState() error {
+	if err := writeFileAtomic(r.stateFile, []byte(r.branchID+"\n")); err != nil {
+		return fmt.Errorf("error writing state file '%s': %s", r.stateFile, err)
+	}
+	return nil
+}
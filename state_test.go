@@ -0,0 +1,146 @@
+package autotag
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+	"github.com/gogs/git-module"
+)
+
+func TestStateFile(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[minor] new feature")
+
+	state := filepath.Join(t.TempDir(), "autotag.state")
+	cfg := GitRepoConfig{
+		RepoPath:  repo.Path(),
+		Branch:    "main",
+		Prefix:    true,
+		StateFile: state,
+	}
+
+	// first run, without state, nothing is new since a previous run
+	r, err := NewRepo(cfg)
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+	assert.Equal(t, BumpNone, r.PreviousRunBump())
+	assert.Equal(t, 0, r.Stats().CommitsSincePreviousRun)
+
+	// NewRepo doesn't write the state
+	_, err = os.Stat(state)
+	assert.IsError(t, err, fs.ErrNotExist)
+	checkFatal(t, os.WriteFile(state, []byte(runGit(t, repo, "rev-parse", "HEAD")+"\n"), 0o644))
+
+	// the next run reports the commits since the first run, the version is still bumped from the base tag
+	updateReadme(t, repo, "a fix")
+	updateReadme(t, repo, "another fix")
+	r, err = NewRepo(cfg)
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+	assert.Equal(t, BumpPatch, r.PreviousRunBump())
+	assert.Equal(t, 3, r.Stats().CommitsScanned)
+	assert.Equal(t, 2, r.Stats().CommitsSincePreviousRun)
+
+	// the state is written once the tag is created
+	_, err = r.AutoTag()
+	checkFatal(t, err)
+	data, err := os.ReadFile(state)
+	checkFatal(t, err)
+	assert.Equal(t, runGit(t, repo, "rev-parse", "HEAD")+"\n", string(data))
+}
+
+func TestStateFilePreview(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[major] breaking change")
+
+	state := filepath.Join(t.TempDir(), "autotag.state")
+	cfg := GitRepoConfig{
+		RepoPath:  repo.Path(),
+		Branch:    "main",
+		Prefix:    true,
+		StateFile: state,
+	}
+
+	// a preview, with -n or a dry run, doesn't move the state
+	r, err := NewRepo(cfg)
+	checkFatal(t, err)
+	assert.Equal(t, "2.0.0", r.LatestVersion())
+
+	preview := cfg
+	preview.DryRun = true
+	r, err = NewRepo(preview)
+	checkFatal(t, err)
+	result, err := r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, "v2.0.0", result.Tag)
+	_, err = os.Stat(state)
+	assert.IsError(t, err, fs.ErrNotExist)
+
+	// the tagging run still includes the previewed breaking change
+	updateReadme(t, repo, "a fix")
+	r, err = NewRepo(cfg)
+	checkFatal(t, err)
+	result, err = r.AutoTag()
+	checkFatal(t, err)
+	assert.Equal(t, "v2.0.0", result.Tag)
+}
+
+func TestStateFileInvalid(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "a fix")
+
+	state := filepath.Join(t.TempDir(), "autotag.state")
+	checkFatal(t, os.WriteFile(state, []byte("--all\n"), 0o644))
+
+	_, err = NewRepo(GitRepoConfig{
+		RepoPath:  repo.Path(),
+		Branch:    "main",
+		StateFile: state,
+	})
+	assert.EqualError(t, err, fmt.Sprintf("state file '%s' does not contain a commit SHA", state))
+}
+
+func TestStateFileNotOnBranch(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	runGit(t, repo, "checkout", "-q", "-b", "other")
+	updateReadme(t, repo, "other change")
+	other := runGit(t, repo, "rev-parse", "HEAD")
+	runGit(t, repo, "checkout", "-q", "main")
+	updateReadme(t, repo, "[minor] new feature")
+
+	// a commit that is not on the branch, eg: after a rebase, falls back to the base tag
+	state := filepath.Join(t.TempDir(), "autotag.state")
+	checkFatal(t, os.WriteFile(state, []byte(other+"\n"), 0o644))
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:  repo.Path(),
+		Branch:    "main",
+		StateFile: state,
+	})
+	checkFatal(t, err)
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+}
@@ -0,0 +1,53 @@
+package autotag
+
+// Stats are counters collected while calculating the next version. They are useful for monitoring
+// autotag across many repositories, eg: to notice a run suddenly scanning thousands of commits.
+type Stats struct {
+	// CommitsScanned is the number of commits between the base tag and the branch head.
+	CommitsScanned int
+
+	// CommitsSincePreviousRun is the number of the scanned commits made since the previous run
+	// recorded in the StateFile.
+	CommitsSincePreviousRun int
+
+	// TagsParsed is the number of tags recognized as versions.
+	TagsParsed int
+
+	// TagsSkipped is the number of tags ignored because they are not versions.
+	TagsSkipped int
+
+	// Bump is the bump decided for the new version: "major", "minor", "patch" or "none".
+	Bump string
+
+	// ClampedCommits are the IDs of the commits that requested a major bump which LockMajor applied
+	// as a minor bump, so a clamped breaking change does not go unnoticed.
+	ClampedCommits []string
+}
+
+// Stats returns the counters collected while calculating the next version.
+func (r *GitRepo) Stats() Stats {
+	return r.stats
+}
+
+// CommitMatch describes how the commit message scheme read a commit.
+type CommitMatch struct {
+	// SHA is the ID of the commit.
+	SHA string
+
+	// Summary is the first line of the commit message.
+	Summary string
+
+	// Bump is the bump requested by the commit: "major", "minor", "patch" or "none". A bump clamped
+	// by LockMajor is reported as requested.
+	Bump string
+
+	// Rule describes the scheme rule that matched, eg: "matched majorRex via `#major`" or
+	// "conventional type `feat` -> minor".
+	Rule string
+}
+
+// MatchDetails returns how each commit checked for the new version was read, oldest first. Skipped
+// merge commits are not included.
+func (r *GitRepo) MatchDetails() []CommitMatch {
+	return r.matches
+}
@@ -0,0 +1,116 @@
+package autotag
+
+import (
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+	"github.com/gogs/git-module"
+)
+
+func TestStats(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	makeTag(repo, "not-a-version")
+	updateReadme(t, repo, "a basic change")
+	makeTag(repo, "v1.0.1-rc.1")
+	updateReadme(t, repo, "[minor] new feature")
+	updateReadme(t, repo, "another change")
+
+	var reported Stats
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+		Metrics:  func(s Stats) { reported = s },
+	})
+	checkFatal(t, err)
+
+	expected := Stats{
+		CommitsScanned: 3,
+		TagsParsed:     2,
+		TagsSkipped:    1,
+		Bump:           "minor",
+	}
+	assert.Equal(t, expected, r.Stats())
+	assert.Equal(t, expected, reported)
+}
+
+func TestStatsClampedCommits(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	seedTestRepo(t, "v1.0.0", repo)
+	updateReadme(t, repo, "[major] breaking change")
+	clamped := runGit(t, repo, "rev-parse", "HEAD")
+	updateReadme(t, repo, "[minor] new feature")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath:  repo.Path(),
+		Branch:    "main",
+		LockMajor: true,
+	})
+	checkFatal(t, err)
+
+	assert.Equal(t, "1.1.0", r.LatestVersion())
+	assert.Equal(t, []string{clamped}, r.Stats().ClampedCommits)
+	assert.Equal(t, "minor", r.Stats().Bump)
+}
+
+func TestMatchDetails(t *testing.T) {
+	tests := []struct {
+		name     string
+		scheme   string
+		commits  []string
+		expected []CommitMatch
+	}{
+		{
+			name:    "autotag",
+			scheme:  "autotag",
+			commits: []string{"#major breaking", "[minor] new feature", "a fix"},
+			expected: []CommitMatch{
+				{Summary: "#major breaking", Bump: "major", Rule: "matched majorRex via `#major`"},
+				{Summary: "[minor] new feature", Bump: "minor", Rule: "matched minorRex via `[minor]`"},
+				{Summary: "a fix", Bump: "none", Rule: "no bump directive"},
+			},
+		},
+		{
+			name:    "conventional",
+			scheme:  "conventional",
+			commits: []string{"feat: new feature", "fix(api)!: breaking fix", "fix: a fix\n\nBREAKING CHANGE: removed it", "wip: stuff"},
+			expected: []CommitMatch{
+				{Summary: "feat: new feature", Bump: "minor", Rule: "conventional type `feat` -> minor"},
+				{Summary: "fix(api)!: breaking fix", Bump: "major", Rule: "conventional type `fix!` -> major"},
+				{Summary: "fix: a fix", Bump: "major", Rule: "footer `BREAKING CHANGE:` -> major"},
+				{Summary: "wip: stuff", Bump: "none", Rule: "conventional type `wip` is not recognized"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			for i, msg := range tc.commits {
+				updateReadme(t, repo, msg)
+				tc.expected[i].SHA = runGit(t, repo, "rev-parse", "HEAD")
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: repo.Path(),
+				Branch:   "main",
+				Scheme:   tc.scheme,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.MatchDetails())
+		})
+	}
+}
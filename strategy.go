@@ -0,0 +1,117 @@
+package autotag
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// VersionStrategy implements the version arithmetic used to calculate the next version: parsing
+// versions from tags, ordering them, bumping them and formatting them for the new tag. The default
+// SemVerStrategy implements SemVer style `Major.Minor.Patch` versions; a custom strategy can be
+// provided in GitRepoConfig to support other conventions such as four-segment versions.
+type VersionStrategy interface {
+	// Parse returns the version for a tag name with any tag prefix already removed. A nil version
+	// and nil error indicates the tag is not a version tag and should be skipped.
+	Parse(tag string) (*version.Version, error)
+
+	// Compare returns -1, 0, or 1 if version a is smaller, equal, or larger than version b.
+	Compare(a, b *version.Version) int
+
+	// BumpMajor returns the next major version.
+	BumpMajor(v *version.Version) (*version.Version, error)
+
+	// BumpMinor returns the next minor version.
+	BumpMinor(v *version.Version) (*version.Version, error)
+
+	// BumpPatch returns the next patch version.
+	BumpPatch(v *version.Version) (*version.Version, error)
+
+	// Format returns the version string used in the tag name, without any tag prefix.
+	Format(v *version.Version) string
+}
+
+// SemVerStrategy is the default VersionStrategy implementing SemVer versions. It can be embedded in
+// a custom strategy that only overrides some of its behavior; a strategy with more than three segments
+// must override Parse, eg: FourSegmentStrategy.
+type SemVerStrategy struct{}
+
+// Parse parses a SemVer version, allowing a leading 'v'. A version with more than three segments is not
+// SemVer and returns an error, so tags such as v1.2.3.4 are skipped.
+func (SemVerStrategy) Parse(tag string) (*version.Version, error) {
+	v, err := parseVersion(tag)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if n := len(v.Segments()); n > 3 {
+		return nil, fmt.Errorf("version '%s' has %d segments, SemVer has 3", tag, n)
+	}
+	return v, nil
+}
+
+// Compare orders versions according to SemVer precedence.
+func (SemVerStrategy) Compare(a, b *version.Version) int {
+	return a.Compare(b)
+}
+
+// BumpMajor bumps the version one major rev 1.0.0 -> 2.0.0
+func (SemVerStrategy) BumpMajor(v *version.Version) (*version.Version, error) {
+	return majorBumper.bump(v)
+}
+
+// BumpMinor bumps the version one minor rev 1.1.0 -> 1.2.0
+func (SemVerStrategy) BumpMinor(v *version.Version) (*version.Version, error) {
+	return minorBumper.bump(v)
+}
+
+// BumpPatch bumps the version one patch rev 1.1.1 -> 1.1.2
+func (SemVerStrategy) BumpPatch(v *version.Version) (*version.Version, error) {
+	return patchBumper.bump(v)
+}
+
+// Format returns the SemVer string of the version.
+func (SemVerStrategy) Format(v *version.Version) string {
+	return v.String()
+}
+
+// FourSegmentStrategy is the VersionStrategy for `Major.Minor.Patch.Revision` versions, eg: 1.2.3.4,
+// enabled by FourSegment. The bumps apply to the first three segments and reset the segments after
+// them, eg: a patch bump of 1.2.3.4 gives 1.2.4.0. A version with three segments has revision 0, and
+// tags with more than four segments are skipped.
+type FourSegmentStrategy struct {
+	SemVerStrategy
+}
+
+// Parse parses a version of up to four segments, allowing a leading 'v'.
+func (FourSegmentStrategy) Parse(tag string) (*version.Version, error) {
+	v, err := parseVersion(tag)
+	if err != nil || v == nil || len(v.Segments()) > 4 {
+		return nil, err
+	}
+	return v, nil
+}
+
+// BumpMajor bumps the version one major rev 1.2.3.4 -> 2.0.0.0
+func (FourSegmentStrategy) BumpMajor(v *version.Version) (*version.Version, error) {
+	s := fourSegments(v)
+	return version.NewVersion(fmt.Sprintf("%d.0.0.0", s[0]+1))
+}
+
+// BumpMinor bumps the version one minor rev 1.2.3.4 -> 1.3.0.0
+func (FourSegmentStrategy) BumpMinor(v *version.Version) (*version.Version, error) {
+	s := fourSegments(v)
+	return version.NewVersion(fmt.Sprintf("%d.%d.0.0", s[0], s[1]+1))
+}
+
+// BumpPatch bumps the version one patch rev 1.2.3.4 -> 1.2.4.0
+func (FourSegmentStrategy) BumpPatch(v *version.Version) (*version.Version, error) {
+	s := fourSegments(v)
+	return version.NewVersion(fmt.Sprintf("%d.%d.%d.0", s[0], s[1], s[2]+1))
+}
+
+// fourSegments returns the first four segments of the version, missing segments are 0
+func fourSegments(v *version.Version) [4]int64 {
+	var s [4]int64
+	copy(s[:], v.Segments64())
+	return s
+}
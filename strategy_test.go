@@ -0,0 +1,186 @@
+package autotag
+
+import (
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+	"github.com/gogs/git-module"
+)
+
+func TestVersionStrategyFourSegment(t *testing.T) {
+	tests := []struct {
+		name        string
+		commit      string
+		expectedTag string
+	}{
+		{
+			name:        "major bump",
+			commit:      "[major] breaking change",
+			expectedTag: "v2.0.0.0",
+		},
+		{
+			name:        "minor bump",
+			commit:      "[minor] new feature",
+			expectedTag: "v1.3.0.0",
+		},
+		{
+			name:        "patch bump",
+			commit:      "[patch] bug fix",
+			expectedTag: "v1.2.4.0",
+		},
+		{
+			name:        "fallback patch bump",
+			commit:      "just a change",
+			expectedTag: "v1.2.4.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.2.3.4", repo)
+			makeTag(repo, "v1.2.3.3")
+			updateReadme(t, repo, tc.commit)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:        repo.Path(),
+				Branch:          "main",
+				Prefix:          true,
+				VersionStrategy: FourSegmentStrategy{},
+			})
+			checkFatal(t, err)
+			assert.Equal(t, "1.2.3.4", r.currentVersion.String())
+
+			_, err = r.AutoTag()
+			assert.NoError(t, err)
+
+			tags, err := r.repo.Tags()
+			checkFatal(t, err)
+			assert.SliceContains(t, tags, tc.expectedTag)
+		})
+	}
+}
+
+func TestFourSegmentTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		fourSegment bool
+		commit      string
+		baseTag     string
+		expected    string
+		parsed      int
+	}{
+		{
+			name:     "rejected by default",
+			commit:   "a fix",
+			baseTag:  "v1.2.3",
+			expected: "1.2.4",
+			parsed:   1,
+		},
+		{
+			name:        "patch bump",
+			fourSegment: true,
+			commit:      "a fix",
+			baseTag:     "v1.2.3.4",
+			expected:    "1.2.4.0",
+			parsed:      2,
+		},
+		{
+			name:        "minor bump",
+			fourSegment: true,
+			commit:      "[minor] new feature",
+			baseTag:     "v1.2.3.4",
+			expected:    "1.3.0.0",
+			parsed:      2,
+		},
+		{
+			name:        "major bump",
+			fourSegment: true,
+			commit:      "[major] breaking change",
+			baseTag:     "v1.2.3.4",
+			expected:    "2.0.0.0",
+			parsed:      2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.2.3", repo)
+			updateReadme(t, repo, "revision")
+			makeTag(repo, "v1.2.3.4")
+			makeTag(repo, "v1.2.3.4.5")
+			updateReadme(t, repo, tc.commit)
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:    repo.Path(),
+				Branch:      "main",
+				Prefix:      true,
+				FourSegment: tc.fourSegment,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.baseTag, r.currentTagName)
+			assert.Equal(t, tc.expected, r.LatestVersion())
+			assert.Equal(t, tc.parsed, r.Stats().TagsParsed)
+		})
+	}
+}
+
+func TestFourSegmentStrategy(t *testing.T) {
+	s := FourSegmentStrategy{}
+
+	v, err := s.Parse("v1.2.3")
+	checkFatal(t, err)
+	next, err := s.BumpPatch(v)
+	checkFatal(t, err)
+	assert.Equal(t, "1.2.4.0", s.Format(next))
+
+	v, err = s.Parse("v1.2.3.4.5")
+	assert.NoError(t, err)
+	assert.Zero(t, v)
+}
+
+func TestSemVerStrategy(t *testing.T) {
+	s := SemVerStrategy{}
+
+	v, err := s.Parse("v1.2.3")
+	checkFatal(t, err)
+	assert.Equal(t, "1.2.3", s.Format(v))
+
+	next, err := s.BumpMinor(v)
+	checkFatal(t, err)
+	assert.Equal(t, "1.3.0", s.Format(next))
+	assert.Equal(t, 1, s.Compare(next, v))
+	assert.Equal(t, -1, s.Compare(v, next))
+	assert.Equal(t, 0, s.Compare(v, v))
+}
+
+func TestSemVerStrategyParseSegments(t *testing.T) {
+	type embedded struct {
+		SemVerStrategy
+	}
+	strategies := map[string]VersionStrategy{
+		"value":    SemVerStrategy{},
+		"pointer":  &SemVerStrategy{},
+		"embedded": embedded{},
+	}
+	for name, strategy := range strategies {
+		t.Run(name, func(t *testing.T) {
+			v, err := strategy.Parse("v1.2.3")
+			assert.NoError(t, err)
+			assert.Equal(t, "1.2.3", v.String())
+
+			v, err = strategy.Parse("v1.2.3.4")
+			assert.EqualError(t, err, "version 'v1.2.3.4' has 4 segments, SemVer has 3")
+			assert.Zero(t, v)
+		})
+	}
+}
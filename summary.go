@@ -0,0 +1,82 @@
+package autotag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Summary describes the calculated release, see WriteSummary.
+type Summary struct {
+	// Version is the new version, eg: `1.2.3`.
+	Version string `json:"version"`
+
+	// Previous is the version of the base tag.
+	Previous string `json:"previous"`
+
+	// Bump is the bump the new version was calculated with, see GitRepo.BumpType: "major", "minor",
+	// "patch" or "none".
+	Bump string `json:"bump"`
+
+	// Commit is the ID of the branch head the new version is for.
+	Commit string `json:"commit"`
+
+	// Changelog lists the subject of every commit in the release, oldest first.
+	Changelog []string `json:"changelog"`
+}
+
+// WriteSummary writes a summary of the calculated release to w in the format "json", "yaml" or
+// "text", eg: for piping into other tools. The new tag does not need to be created yet.
+func (r *GitRepo) WriteSummary(w io.Writer, format string) error {
+	changelog, err := r.releaseSubjects()
+	if err != nil {
+		return err
+	}
+	s := Summary{
+		Version:   r.LatestVersion(),
+		Previous:  r.strategy.Format(r.currentVersion),
+		Bump:      r.bumpName(),
+		Commit:    r.branchID,
+		Changelog: changelog,
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case "yaml":
+		// double quoted scalars are valid YAML and need no escaping beyond strconv.Quote
+		var b strings.Builder
+		fmt.Fprintf(&b, "version: %s\n", strconv.Quote(s.Version))
+		fmt.Fprintf(&b, "previous: %s\n", strconv.Quote(s.Previous))
+		fmt.Fprintf(&b, "bump: %s\n", strconv.Quote(s.Bump))
+		fmt.Fprintf(&b, "commit: %s\n", strconv.Quote(s.Commit))
+		if len(s.Changelog) == 0 {
+			b.WriteString("changelog: []\n")
+		} else {
+			b.WriteString("changelog:\n")
+			for _, c := range s.Changelog {
+				fmt.Fprintf(&b, "  - %s\n", strconv.Quote(c))
+			}
+		}
+		_, err = io.WriteString(w, b.String())
+		return err
+	case "text":
+		var b strings.Builder
+		fmt.Fprintf(&b, "Version:  %s\n", s.Version)
+		fmt.Fprintf(&b, "Previous: %s\n", s.Previous)
+		fmt.Fprintf(&b, "Bump:     %s\n", s.Bump)
+		fmt.Fprintf(&b, "Commit:   %s\n", s.Commit)
+		b.WriteString("Changelog:\n")
+		for _, c := range s.Changelog {
+			fmt.Fprintf(&b, "- %s\n", c)
+		}
+		_, err = io.WriteString(w, b.String())
+		return err
+	default:
+		return fmt.Errorf("summary format '%s' is not valid; must be (json|yaml|text)", format)
+	}
+}
@@ -0,0 +1,70 @@
+package autotag
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	assert "github.com/alecthomas/assert/v2"
+)
+
+func TestWriteSummary(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.0.0",
+		commitList: []string{"[minor] add \"quoted\" flag", "fix a bug"},
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "json",
+			expected: fmt.Sprintf(`{
+  "version": "1.1.0",
+  "previous": "1.0.0",
+  "bump": "minor",
+  "commit": "%s",
+  "changelog": [
+    "[minor] add \"quoted\" flag",
+    "fix a bug"
+  ]
+}
+`, r.branchID),
+		},
+		{
+			format: "yaml",
+			expected: fmt.Sprintf(`version: "1.1.0"
+previous: "1.0.0"
+bump: "minor"
+commit: "%s"
+changelog:
+  - "[minor] add \"quoted\" flag"
+  - "fix a bug"
+`, r.branchID),
+		},
+		{
+			format: "text",
+			expected: fmt.Sprintf(`Version:  1.1.0
+Previous: 1.0.0
+Bump:     minor
+Commit:   %s
+Changelog:
+- [minor] add "quoted" flag
+- fix a bug
+`, r.branchID),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, r.WriteSummary(&buf, tc.format))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	assert.Error(t, r.WriteSummary(&bytes.Buffer{}, "xml"))
+}
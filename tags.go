@@ -0,0 +1,168 @@
+package autotag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogs/git-module"
+	"github.com/hashicorp/go-version"
+)
+
+// TagInfo describes a version tag found in the repository.
+type TagInfo struct {
+	// Name is the full tag name, including any prefix, eg: `v1.2.3`.
+	Name string
+
+	Version *version.Version
+
+	// SHA is the ID of the tagged commit.
+	SHA string
+
+	// Date is the author or committer date of the tagged commit, see DateSource.
+	Date time.Time
+}
+
+// TagsBetween returns the version tags whose commit date, see DateSource, falls within [start, end), newest version
+// first. It is useful for release cadence reporting, eg: counting the releases cut last quarter.
+func (r *GitRepo) TagsBetween(start, end time.Time) ([]TagInfo, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("end '%s' is before start '%s'", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+
+	var tags []TagInfo
+	for _, t := range r.tags {
+		if !t.Date.Before(start) && t.Date.Before(end) {
+			tags = append(tags, t)
+		}
+	}
+	return tags, nil
+}
+
+// TagCommits maps the versions of the version tags, eg: `1.2.3`, to the SHA of the tagged commit, eg:
+// to correlate deployed versions with commits. The map is a copy the caller may modify.
+func (r *GitRepo) TagCommits() map[string]string {
+	commits := make(map[string]string, len(r.tags))
+	for _, t := range r.tags {
+		commits[t.Version.String()] = t.SHA
+	}
+	return commits
+}
+
+// PreReleasesFor returns the pre-release versions of the given base version, eg: `v1.2.0-rc.1` and
+// `v1.2.0-rc.2` for `1.2.0`, in order of precedence. Any pre-release or metadata of base is ignored.
+func (r *GitRepo) PreReleasesFor(base string) ([]*version.Version, error) {
+	b, err := r.strategy.Parse(base)
+	if err != nil || b == nil {
+		return nil, fmt.Errorf("'%s' is not a valid version", base)
+	}
+
+	var versions []*version.Version
+	// tags are ordered newest version first
+	for i := len(r.tags) - 1; i >= 0; i-- {
+		v := r.tags[i].Version
+		if v.Prerelease() != "" && v.Core().Equal(b.Core()) {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// PreReleaseGaps returns the pre-release numbers missing from the sequence of the named pre-release
+// channel by base version, eg: {"1.1.0": [2]} for `v1.1.0-rc.1` and `v1.1.0-rc.3`, in increasing
+// order. The numbers restart at 1 for every base version, which is only included if it has gaps.
+func (r *GitRepo) PreReleaseGaps(name string) (map[string][]uint64, error) {
+	if !validateSemVerPreReleaseName(name) {
+		return nil, fmt.Errorf("'%s' is not valid SemVer pre-release name", name)
+	}
+
+	seen := make(map[string]map[uint64]bool)
+	highest := make(map[string]uint64)
+	for _, t := range r.tags {
+		counter, ok := strings.CutPrefix(t.Version.Prerelease(), name+".")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			continue
+		}
+		base := t.Version.Core().String()
+		if seen[base] == nil {
+			seen[base] = make(map[uint64]bool)
+		}
+		seen[base][n] = true
+		if n > highest[base] {
+			highest[base] = n
+		}
+	}
+
+	gaps := make(map[string][]uint64)
+	for base, numbers := range seen {
+		for n := uint64(1); n < highest[base]; n++ {
+			if !numbers[n] {
+				gaps[base] = append(gaps[base], n)
+			}
+		}
+	}
+	return gaps, nil
+}
+
+// Describe returns a `git describe` style string for the branch head, eg: `v1.2.3-5-gabc1234`: the
+// highest version tag reachable from the head, the number of commits since it and the abbreviated
+// commit ID. It does not depend on the calculated version, eg: for stamping builds that are not tagged.
+func (r *GitRepo) Describe() (string, error) {
+	// a constant number of git calls, however many tags there are
+	out, err := git.NewCommand("for-each-ref", "--merged", r.branchID, "--format=%(refname)", r.tagRefNamespace+"/").RunInDir(r.repo.Path())
+	if err != nil {
+		return "", err
+	}
+	reachable := make(map[string]bool)
+	for _, ref := range strings.Fields(string(out)) {
+		reachable[ref] = true
+	}
+	// tags are newest version first
+	var nearest *TagInfo
+	for i := range r.tags {
+		if reachable[r.tagRef(r.tags[i].Name)] {
+			nearest = &r.tags[i]
+			break
+		}
+	}
+	if nearest == nil {
+		return "", fmt.Errorf("no version tag reachable from '%s'", r.branch)
+	}
+
+	out, err = git.NewCommand("rev-list", "--count", nearest.SHA+".."+r.branchID).RunInDir(r.repo.Path())
+	if err != nil {
+		return "", err
+	}
+	distance, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return "", err
+	}
+
+	short, err := git.NewCommand("rev-parse", "--short=7", r.branchID).RunInDir(r.repo.Path())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d-g%s", nearest.Name, distance, strings.TrimSpace(string(short))), nil
+}
+
+// CompareURL returns a link to the diff between the base tag and the new tag on the forge hosting the
+// repository at base, eg: `https://github.com/org/repo/compare/v1.2.2...v1.2.3`. The URL shape is
+// selected with ForgeType.
+func (r *GitRepo) CompareURL(base string) string {
+	base = strings.TrimSuffix(strings.TrimRight(base, "/"), ".git")
+	path := "/compare/"
+	if r.forgeType == "gitlab" {
+		path = "/-/compare/"
+	}
+	// without a base tag, eg: with BaseVersion, the diff starts at the base commit
+	from := r.currentTagName
+	if from == "" {
+		from = r.currentTag.ID.String()
+	}
+	return base + path + from + "..." + r.displayTagName(r.newVersion)
+}
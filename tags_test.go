@@ -0,0 +1,346 @@
+package autotag
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	assert "github.com/alecthomas/assert/v2"
+	"github.com/gogs/git-module"
+)
+
+func TestTagsBetween(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	timeline := []struct {
+		tag  string
+		date string
+	}{
+		{tag: "v1.0.0", date: "2020-01-15T00:00:00Z"},
+		{tag: "v1.1.0", date: "2020-04-10T00:00:00Z"},
+		{tag: "v1.2.0", date: "2020-05-20T00:00:00Z"},
+		{tag: "v2.0.0", date: "2020-07-01T00:00:00Z"},
+	}
+	for _, e := range timeline {
+		t.Setenv("GIT_AUTHOR_DATE", e.date)
+		t.Setenv("GIT_COMMITTER_DATE", e.date)
+		updateReadme(t, repo, e.tag)
+		makeTag(repo, e.tag)
+	}
+	updateReadme(t, repo, "unreleased change")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+		Prefix:   true,
+	})
+	checkFatal(t, err)
+
+	q2Start := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	q2End := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tags, err := r.TagsBetween(q2Start, q2End)
+	checkFatal(t, err)
+
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+		assert.Equal(t, runGit(t, repo, "rev-list", "-n1", tag.Name), tag.SHA)
+	}
+	assert.Equal(t, []string{"v1.2.0", "v1.1.0"}, names)
+	assert.Equal(t, "1.2.0", tags[0].Version.String())
+	assert.True(t, tags[0].Date.Equal(time.Date(2020, 5, 20, 0, 0, 0, 0, time.UTC)))
+
+	_, err = r.TagsBetween(q2End, q2Start)
+	assert.Error(t, err)
+}
+
+func TestTagsBetweenDateSource(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	// v1.1.0 was authored in March but rebased and committed in May
+	timeline := []struct {
+		tag       string
+		authored  string
+		committed string
+	}{
+		{tag: "v1.0.0", authored: "2020-01-15T00:00:00Z", committed: "2020-01-15T00:00:00Z"},
+		{tag: "v1.1.0", authored: "2020-03-10T00:00:00Z", committed: "2020-05-10T00:00:00Z"},
+		{tag: "v1.2.0", authored: "2020-05-20T00:00:00Z", committed: "2020-05-20T00:00:00Z"},
+	}
+	for _, e := range timeline {
+		t.Setenv("GIT_AUTHOR_DATE", e.authored)
+		t.Setenv("GIT_COMMITTER_DATE", e.committed)
+		updateReadme(t, repo, e.tag)
+		makeTag(repo, e.tag)
+	}
+
+	q2Start := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	q2End := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		dateSource string
+		expected   []string
+	}{
+		{dateSource: "", expected: []string{"v1.2.0"}},
+		{dateSource: "author", expected: []string{"v1.2.0"}},
+		{dateSource: "committer", expected: []string{"v1.2.0", "v1.1.0"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dateSource, func(t *testing.T) {
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:   repo.Path(),
+				Branch:     "main",
+				DateSource: tc.dateSource,
+			})
+			checkFatal(t, err)
+
+			tags, err := r.TagsBetween(q2Start, q2End)
+			checkFatal(t, err)
+			var names []string
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestTagCommits(t *testing.T) {
+	tr := createTestRepo(t, "main")
+	repo, err := git.Open(tr)
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, repo)
+
+	expected := map[string]string{}
+	for _, tag := range []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0", "v1.2.0+build.5"} {
+		updateReadme(t, repo, tag)
+		makeTag(repo, tag)
+		expected[tag[1:]] = runGit(t, repo, "rev-parse", "HEAD")
+	}
+	// annotated tags map to the tagged commit, not the tag object
+	updateReadme(t, repo, "v2.0.0")
+	runGit(t, repo, "tag", "-a", "-m", "release", "v2.0.0")
+	expected["2.0.0"] = runGit(t, repo, "rev-parse", "HEAD")
+	makeTag(repo, "not-a-version")
+	updateReadme(t, repo, "unreleased change")
+
+	r, err := NewRepo(GitRepoConfig{
+		RepoPath: repo.Path(),
+		Branch:   "main",
+		Prefix:   true,
+	})
+	checkFatal(t, err)
+
+	commits := r.TagCommits()
+	assert.Equal(t, expected, commits)
+
+	// the map is a copy
+	delete(commits, "1.0.0")
+	commits["3.0.0"] = "deadbeef"
+	assert.Equal(t, expected, r.TagCommits())
+}
+
+func TestPreReleasesFor(t *testing.T) {
+	r, err := newTestRepo(t, testRepoSetup{
+		initialTag: "v1.1.0",
+		extraTags:  []string{"v1.2.0-rc.2", "v1.2.0-rc.10", "v1.2.0-beta.1", "v1.2.1-rc.1", "v1.3.0-rc.1", "v1.2.0"},
+		nextCommit: "a change",
+	})
+	checkFatal(t, err)
+	defer cleanupTestRepo(t, r.repo)
+	updateReadme(t, r.repo, "release candidate")
+	makeTag(r.repo, "v1.2.0-rc.1")
+
+	next, err := NewRepo(GitRepoConfig{
+		RepoPath: repoRoot(r.repo),
+		Branch:   "main",
+	})
+	checkFatal(t, err)
+
+	versions, err := next.PreReleasesFor("v1.2.0")
+	checkFatal(t, err)
+	var names []string
+	for _, v := range versions {
+		names = append(names, v.String())
+	}
+	assert.Equal(t, []string{"1.2.0-beta.1", "1.2.0-rc.1", "1.2.0-rc.2", "1.2.0-rc.10"}, names)
+
+	versions, err = next.PreReleasesFor("2.0.0")
+	checkFatal(t, err)
+	assert.Equal(t, 0, len(versions))
+
+	_, err = next.PreReleasesFor("not-a-version")
+	assert.Error(t, err)
+}
+
+func TestPreReleaseGaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected map[string][]uint64
+	}{
+		{
+			name:     "gap",
+			tags:     []string{"v1.1.0-rc.1", "v1.1.0-rc.3", "v1.1.0-rc.6", "v1.1.0-beta.2"},
+			expected: map[string][]uint64{"1.1.0": {2, 4, 5}},
+		},
+		{
+			name:     "contiguous",
+			tags:     []string{"v1.1.0-rc.1", "v1.1.0-rc.2", "v1.2.0-rc.1", "v1.1.0-beta.5"},
+			expected: map[string][]uint64{},
+		},
+		{
+			name:     "numbers restart for every base version",
+			tags:     []string{"v1.0.0-rc.1", "v1.0.0-rc.3", "v1.0.1-rc.1", "v1.0.1-rc.2"},
+			expected: map[string][]uint64{"1.0.0": {2}},
+		},
+		{
+			name:     "gaps in several base versions",
+			tags:     []string{"v1.0.1-rc.2", "v1.1.0-rc.1", "v1.1.0-rc.3"},
+			expected: map[string][]uint64{"1.0.1": {1}, "1.1.0": {2}},
+		},
+		{
+			name:     "no pre-releases",
+			expected: map[string][]uint64{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestRepo(t, testRepoSetup{
+				initialTag: "v1.0.0",
+				nextCommit: "a change",
+			})
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, r.repo)
+			for _, tag := range tc.tags {
+				makeTag(r.repo, tag)
+			}
+			updateReadme(t, r.repo, "another change")
+
+			next, err := NewRepo(GitRepoConfig{
+				RepoPath: repoRoot(r.repo),
+				Branch:   "main",
+			})
+			checkFatal(t, err)
+
+			gaps, err := next.PreReleaseGaps("rc")
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, gaps)
+		})
+	}
+
+	r := GitRepo{}
+	_, err := r.PreReleaseGaps("rc..1")
+	assert.Error(t, err)
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name     string
+		commits  int
+		headTag  string
+		expected string
+	}{
+		{
+			name:     "exact tag",
+			commits:  1,
+			headTag:  "v1.1.0",
+			expected: "v1.1.0-0-g",
+		},
+		{
+			name:     "commits ahead",
+			commits:  3,
+			expected: "v1.0.0-3-g",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+
+			// a higher version that is not reachable from main
+			runGit(t, repo, "checkout", "-q", "-b", "other")
+			updateReadme(t, repo, "other change")
+			makeTag(repo, "v1.5.0")
+			runGit(t, repo, "checkout", "-q", "main")
+
+			for i := 0; i < tc.commits; i++ {
+				updateReadme(t, repo, fmt.Sprintf("change %d", i))
+			}
+			if tc.headTag != "" {
+				makeTag(repo, tc.headTag)
+			}
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath: repo.Path(),
+				Branch:   "main",
+				Prefix:   true,
+			})
+			checkFatal(t, err)
+
+			describe, err := r.Describe()
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected+runGit(t, repo, "rev-parse", "--short=7", "main"), describe)
+		})
+	}
+}
+
+func TestCompareURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		forgeType string
+		base      string
+		expected  string
+	}{
+		{
+			name:     "github",
+			base:     "https://github.com/org/repo",
+			expected: "https://github.com/org/repo/compare/v1.0.0...v1.0.1",
+		},
+		{
+			name:      "gitlab",
+			forgeType: "gitlab",
+			base:      "https://gitlab.com/group/repo/",
+			expected:  "https://gitlab.com/group/repo/-/compare/v1.0.0...v1.0.1",
+		},
+		{
+			name:     "clone url",
+			base:     "https://github.com/org/repo.git",
+			expected: "https://github.com/org/repo/compare/v1.0.0...v1.0.1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := createTestRepo(t, "main")
+			repo, err := git.Open(tr)
+			checkFatal(t, err)
+			defer cleanupTestRepo(t, repo)
+
+			seedTestRepo(t, "v1.0.0", repo)
+			updateReadme(t, repo, "a fix")
+
+			r, err := NewRepo(GitRepoConfig{
+				RepoPath:  repo.Path(),
+				Branch:    "main",
+				Prefix:    true,
+				ForgeType: tc.forgeType,
+			})
+			checkFatal(t, err)
+			assert.Equal(t, tc.expected, r.CompareURL(tc.base))
+		})
+	}
+}